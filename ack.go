@@ -0,0 +1,245 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AckEventName is the name of the event acknowledging an event that was
+// sent with an ID. A client's peer acknowledges such an event by sending
+// an AckEventName event whose data is the event's ID. In return, a peer
+// that sent an event with an ID receives an AckEventName event whose data
+// is a DeliveryReport once the event's delivery is complete.
+const AckEventName = "$ack"
+
+const (
+	// DefaultAckTimeout is how long a Hub waits for an acknowledged event
+	// to be acknowledged before retransmitting it, unless AckOptions say
+	// otherwise.
+	DefaultAckTimeout = 5 * time.Second
+	// DefaultAckRetries is how many times a Hub retransmits an
+	// acknowledged event, unless AckOptions say otherwise.
+	DefaultAckRetries = 3
+)
+
+// AckOptions configure the retransmission of an acknowledged event.
+type AckOptions struct {
+	// Timeout is how long to wait for recipients to acknowledge the event
+	// before retransmitting it to those that have not. Defaults to
+	// DefaultAckTimeout.
+	Timeout time.Duration
+	// Retries is how many times the event is retransmitted before the
+	// recipients that have not acknowledged it are considered failed.
+	// Defaults to DefaultAckRetries. Negative to never retransmit.
+	Retries int
+}
+
+// timeout returns how long to wait for acknowledgements.
+func (o AckOptions) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return DefaultAckTimeout
+	}
+	return o.Timeout
+}
+
+// retries returns how many times to retransmit.
+func (o AckOptions) retries() int {
+	if o.Retries == 0 {
+		return DefaultAckRetries
+	}
+	return max(o.Retries, 0)
+}
+
+// DeliveryReport describes the outcome of an acknowledged event's delivery.
+type DeliveryReport struct {
+	// ID is the ID of the event. In an AckEventName event sent to a peer,
+	// it is the ID the peer sent the event with.
+	ID string `json:"id"`
+	// Delivered are the IDs of the clients that acknowledged the event.
+	Delivered []string `json:"delivered"`
+	// Failed are the IDs of the clients that did not acknowledge the event
+	// before its retries ran out, were unregistered, or were dropped for
+	// not keeping up with events.
+	Failed []string `json:"failed"`
+	// HubClosed is true if the hub had stopped running before the event
+	// was broadcast, so it was sent to no one.
+	HubClosed bool `json:"hubClosed,omitempty"`
+}
+
+// OK returns true if the event was broadcast and every recipient
+// acknowledged it.
+func (r DeliveryReport) OK() bool {
+	return len(r.Failed) == 0 && !r.HubClosed
+}
+
+// delivery tracks an acknowledged event until every recipient has
+// acknowledged it or its retries run out. Only accessed from the Hub's Run
+// goroutine.
+type delivery struct {
+	// options configure the retransmission of the event.
+	options AckOptions
+	// onComplete is called with report once the delivery is complete. May
+	// be nil.
+	onComplete func(DeliveryReport)
+	// clientEvent is the event as the outbound chain delivered it, which
+	// is retransmitted to waiting. Zero until the event is delivered.
+	clientEvent ClientEvent
+	// waiting are the recipients that have not acknowledged the event,
+	// with their IDs.
+	waiting map[Client]string
+	// retries is how many times the event has been retransmitted.
+	retries int
+	// report accumulates the outcome of the delivery.
+	report DeliveryReport
+	// stop is closed once the delivery is complete, stopping its
+	// retransmission timer.
+	stop chan struct{}
+}
+
+// BroadcastAcked sends a message from a client to all registered clients,
+// like BroadcastContext, requiring each recipient to acknowledge it. The
+// event is sent with a unique ID, which recipients acknowledge with Ack
+// (WebsocketClients do so when their peer sends an AckEventName event).
+// The event is retransmitted to recipients that have not acknowledged it
+// as configured by options. Once every recipient has acknowledged it, or
+// its retries run out, onComplete is called with the outcome, off the
+// Hub's Run goroutine like HubObserver notifications. If the hub stops
+// running first, recipients that have not acknowledged the event fail, and
+// if it stops before the event is broadcast, the report's HubClosed is
+// set.
+// onComplete may be nil. Blocks until the message is broadcasted or the
+// hub stops running.
+//
+// Retransmission makes delivery at-least-once, so recipients should ignore
+// events whose ID they have already seen.
+func (h *Hub) BroadcastAcked(ctx context.Context, client Client, event string, b []byte, options AckOptions, onComplete func(DeliveryReport)) {
+	// Event IDs are random, like client IDs.
+	id := newClientID()
+	h.broadcastContext(ctx, client, Event{Name: event, Data: b, ID: id}, &delivery{
+		options:    options,
+		onComplete: onComplete,
+		waiting:    make(map[Client]string),
+		report:     DeliveryReport{ID: id, Delivered: []string{}, Failed: []string{}},
+		stop:       make(chan struct{}),
+	})
+}
+
+// Ack acknowledges that client received the event with the given ID,
+// which it received from BroadcastAcked. Acknowledgements of events that
+// were not sent to client, or whose delivery is complete, are ignored.
+// Blocks until the acknowledgement is applied or the hub stops running.
+func (h *Hub) Ack(client Client, id string) {
+	h.query(func() { h.acknowledge(client, id) })
+}
+
+// track starts retransmitting an acknowledged event, which has just been
+// through the outbound chain. Must only be called from the Run goroutine.
+func (h *Hub) track(d *delivery) {
+	if len(d.waiting) == 0 {
+		// No recipient is left to acknowledge the event, because an
+		// outbound interceptor dropped it, it had no recipients, or they
+		// were all dropped.
+		h.completeDelivery(d)
+		return
+	}
+	h.deliveries[d.report.ID] = d
+	h.scheduleRetransmit(d)
+}
+
+// scheduleRetransmit retransmits an acknowledged event after its timeout,
+// unless its delivery completes first. Must only be called from the Run
+// goroutine.
+func (h *Hub) scheduleRetransmit(d *delivery) {
+	timer := h.clock().NewTimer(d.options.timeout())
+	go func() {
+		select {
+		case <-timer.C():
+			h.query(func() { h.retransmit(d) })
+		case <-d.stop:
+			timer.Stop()
+		}
+	}()
+}
+
+// retransmit sends an acknowledged event again to the recipients that have
+// not acknowledged it, or fails them if its retries have run out. Must only
+// be called from the Run goroutine.
+func (h *Hub) retransmit(d *delivery) {
+	if h.deliveries[d.report.ID] != d {
+		return
+	}
+	if d.retries >= d.options.retries() {
+		for client, id := range d.waiting {
+			delete(d.waiting, client)
+			d.report.Failed = append(d.report.Failed, id)
+		}
+		h.completeDelivery(d)
+		return
+	}
+	d.retries++
+	for client := range d.waiting {
+		// A recipient whose buffer is full is not dropped, since it may
+		// catch up before the next retransmission.
+		select {
+		case client.Send() <- d.clientEvent:
+		default:
+		}
+	}
+	h.scheduleRetransmit(d)
+}
+
+// acknowledge records that client acknowledged the event with the given ID.
+// Must only be called from the Run goroutine.
+func (h *Hub) acknowledge(client Client, id string) {
+	d, ok := h.deliveries[id]
+	if !ok {
+		return
+	}
+	clientID, ok := d.waiting[client]
+	if !ok {
+		return
+	}
+	delete(d.waiting, client)
+	d.report.Delivered = append(d.report.Delivered, clientID)
+	if len(d.waiting) == 0 {
+		h.completeDelivery(d)
+	}
+}
+
+// failDeliveries fails the recipient client of every acknowledged event it
+// has not acknowledged, because it is being unregistered. Since the Hub
+// unregisters every client when it closes, this completes every delivery
+// still pending then. Must only be called from the Run goroutine.
+func (h *Hub) failDeliveries(client Client) {
+	for _, d := range h.deliveries {
+		clientID, ok := d.waiting[client]
+		if !ok {
+			continue
+		}
+		delete(d.waiting, client)
+		d.report.Failed = append(d.report.Failed, clientID)
+		if len(d.waiting) == 0 {
+			h.completeDelivery(d)
+		}
+	}
+}
+
+// completeDelivery stops tracking an acknowledged event and reports its
+// outcome. Must only be called from the Run goroutine.
+func (h *Hub) completeDelivery(d *delivery) {
+	delete(h.deliveries, d.report.ID)
+	close(d.stop)
+	h.logger().Debug("completed acknowledged delivery", "id", d.report.ID, "delivered", len(d.report.Delivered), "failed", len(d.report.Failed))
+	if d.onComplete != nil {
+		report := d.report
+		h.notifications.call(func() { d.onComplete(report) })
+	}
+}
+
+// decodeAck decodes the ID acknowledged by an AckEventName event's data.
+func decodeAck(data json.RawMessage) (string, error) {
+	var id string
+	err := json.Unmarshal(data, &id)
+	return id, err
+}
@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// expectClientEvent fails the test if client does not receive an event soon.
+func expectClientEvent(t *testing.T, client *propertyClient) ClientEvent {
+	t.Helper()
+	select {
+	case clientEvent := <-client.send:
+		return clientEvent
+	case <-time.After(time.Second):
+		t.Fatal("client did not receive an event")
+		return ClientEvent{}
+	}
+}
+
+func TestBroadcastAcked(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	hub := NewHub()
+	hub.Clock = clock
+	go hub.Run()
+	defer hub.Close()
+
+	acking, silent, leaving := newPropertyClient(8, false), newPropertyClient(8, false), newPropertyClient(8, true)
+	hub.Register(acking, ClientRegistrationOptions{})
+	hub.Register(silent, ClientRegistrationOptions{})
+	hub.Register(leaving, ClientRegistrationOptions{})
+	ids := map[Client]string{}
+	hub.query(func() {
+		for client, data := range hub.clients {
+			ids[client] = data.id
+		}
+	})
+
+	reports := make(chan DeliveryReport, 1)
+	hub.BroadcastAcked(context.Background(), nil, "message", []byte(`"hello"`), AckOptions{Timeout: time.Second, Retries: 2}, func(report DeliveryReport) {
+		reports <- report
+	})
+	event := expectClientEvent(t, acking).Event
+	if event.ID == "" {
+		t.Fatal("expected the event to have an ID")
+	}
+	hub.Ack(acking, event.ID)
+	hub.Unregister(leaving)
+
+	// The silent client receives the event again after each timeout until
+	// its retries run out.
+	for i := range 3 {
+		if id := expectClientEvent(t, silent).Event.ID; id != event.ID {
+			t.Fatalf("expected attempt %d to have ID %q, got %q", i, event.ID, id)
+		}
+		clock.BlockUntil(2)
+		clock.Advance(time.Second)
+	}
+	select {
+	case report := <-reports:
+		if report.ID != event.ID || report.OK() {
+			t.Errorf("unexpected report %+v", report)
+		}
+		if !slices.Equal(report.Delivered, []string{ids[acking]}) {
+			t.Errorf("expected %q to be delivered, got %v", ids[acking], report.Delivered)
+		}
+		failed := []string{ids[leaving], ids[silent]}
+		if !slices.Equal(report.Failed, failed) {
+			t.Errorf("expected %v to fail, got %v", failed, report.Failed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("delivery was not reported")
+	}
+	select {
+	case clientEvent := <-silent.send:
+		t.Errorf("unexpected retransmission %+v", clientEvent.Event)
+	default:
+	}
+}
+
+// TestBroadcastAckedDropped checks that a recipient dropped for not
+// keeping up fails the delivery rather than being left out of the report.
+func TestBroadcastAckedDropped(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Close()
+
+	full := newPropertyClient(0, false)
+	hub.Register(full, ClientRegistrationOptions{})
+	var id string
+	hub.query(func() { id = hub.clients[full].id })
+
+	reports := make(chan DeliveryReport, 1)
+	hub.BroadcastAcked(context.Background(), nil, "message", nil, AckOptions{}, func(report DeliveryReport) {
+		reports <- report
+	})
+	select {
+	case report := <-reports:
+		if report.OK() || len(report.Delivered) != 0 || !slices.Equal(report.Failed, []string{id}) {
+			t.Errorf("expected only %q to fail, got %+v", id, report)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("delivery was not reported")
+	}
+}
+
+// TestBroadcastAckedHubClosed checks that deliveries are completed when
+// the Hub stops, whether or not the event reached the Run goroutine.
+func TestBroadcastAckedHubClosed(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	silent := newPropertyClient(8, false)
+	hub.Register(silent, ClientRegistrationOptions{})
+	var id string
+	hub.query(func() { id = hub.clients[silent].id })
+
+	reports := make(chan DeliveryReport, 1)
+	onComplete := func(report DeliveryReport) { reports <- report }
+	expectReport := func(failed []string, hubClosed bool) {
+		t.Helper()
+		select {
+		case report := <-reports:
+			if report.OK() || len(report.Delivered) != 0 || !slices.Equal(report.Failed, failed) || report.HubClosed != hubClosed {
+				t.Errorf("expected only %v to fail with HubClosed %v, got %+v", failed, hubClosed, report)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("delivery was not reported")
+		}
+	}
+	hub.BroadcastAcked(context.Background(), nil, "message", nil, AckOptions{}, onComplete)
+	expectClientEvent(t, silent)
+	hub.Close()
+	expectReport([]string{id}, false)
+
+	// An event broadcast after the hub stops is sent to no one, which
+	// fails rather than trivially succeeding.
+	<-hub.Done()
+	hub.BroadcastAcked(context.Background(), nil, "message", nil, AckOptions{}, onComplete)
+	expectReport([]string{}, true)
+}
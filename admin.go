@@ -0,0 +1,192 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+)
+
+// HubInfo describes a Hub in a HubRegistry.
+type HubInfo struct {
+	// Key is the Hub's Key.
+	Key string `json:"key"`
+	// Clients are the clients registered with the Hub.
+	Clients []ClientInfo `json:"clients"`
+}
+
+// adminHandler is the http.Handler returned by NewAdminHandler.
+type adminHandler struct {
+	hubs *HubRegistry
+	mux  *http.ServeMux
+}
+
+// NewAdminHandler constructs an http.Handler serving a JSON API to inspect
+// and control the hubs in hubs. The API has no authentication of its own,
+// so wrap it in whatever authentication protects the rest of the
+// application's administration. Mount it under a prefix with
+// http.StripPrefix. Its endpoints are:
+//
+//	GET  /hubs                                list hubs and their clients
+//	GET  /hubs/{key}                          describe a hub and its clients
+//	POST /hubs/{key}/broadcast                broadcast {"name", "data"} to a hub
+//	POST /hubs/{key}/close                    close a hub
+//	GET  /hubs/{key}/clients/{id}             describe a client
+//	POST /hubs/{key}/clients/{id}/send        send {"name", "data"} to a client
+//	POST /hubs/{key}/clients/{id}/disconnect  disconnect a client with {"reason"}
+func NewAdminHandler(hubs *HubRegistry) http.Handler {
+	handler := &adminHandler{hubs: hubs, mux: http.NewServeMux()}
+	handler.mux.HandleFunc("GET /hubs", handler.listHubs)
+	handler.mux.HandleFunc("GET /hubs/{key}", handler.getHub)
+	handler.mux.HandleFunc("POST /hubs/{key}/broadcast", handler.broadcast)
+	handler.mux.HandleFunc("POST /hubs/{key}/close", handler.closeHub)
+	handler.mux.HandleFunc("GET /hubs/{key}/clients/{id}", handler.getClient)
+	handler.mux.HandleFunc("POST /hubs/{key}/clients/{id}/send", handler.send)
+	handler.mux.HandleFunc("POST /hubs/{key}/clients/{id}/disconnect", handler.disconnect)
+	return handler
+}
+
+func (a *adminHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	a.mux.ServeHTTP(w, req)
+}
+
+// writeJSON writes value as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes err as a JSON error response, choosing the status from
+// the kind of error.
+func writeError(w http.ResponseWriter, status int, err error) {
+	switch {
+	case errors.Is(err, ErrClientNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrHubClosed):
+		status = http.StatusGone
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// hubInfo describes hub.
+func hubInfo(hub *Hub) (HubInfo, error) {
+	clients, err := hub.Clients()
+	if err != nil {
+		return HubInfo{}, err
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ConnectedAt.Before(clients[j].ConnectedAt) })
+	return HubInfo{Key: hub.Key, Clients: clients}, nil
+}
+
+// hub returns the hub named by the request's key, writing an error response
+// if there is none.
+func (a *adminHandler) hub(w http.ResponseWriter, req *http.Request) (*Hub, bool) {
+	hub, ok := a.hubs.Get(req.PathValue("key"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "hub not found"})
+	}
+	return hub, ok
+}
+
+func (a *adminHandler) listHubs(w http.ResponseWriter, req *http.Request) {
+	infos := []HubInfo{}
+	for _, hub := range a.hubs.Hubs() {
+		info, err := hubInfo(hub)
+		if err != nil {
+			// The hub closed while listing, and will leave the registry.
+			continue
+		}
+		infos = append(infos, info)
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (a *adminHandler) getHub(w http.ResponseWriter, req *http.Request) {
+	hub, ok := a.hub(w, req)
+	if !ok {
+		return
+	}
+	info, err := hubInfo(hub)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (a *adminHandler) broadcast(w http.ResponseWriter, req *http.Request) {
+	hub, ok := a.hub(w, req)
+	if !ok {
+		return
+	}
+	var event Event
+	if err := json.NewDecoder(req.Body).Decode(&event); err != nil || event.Name == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected an event with a name"})
+		return
+	}
+	select {
+	case <-hub.Done():
+		writeError(w, http.StatusGone, ErrHubClosed)
+		return
+	default:
+	}
+	hub.BroadcastContext(req.Context(), nil, event.Name, event.Data)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminHandler) closeHub(w http.ResponseWriter, req *http.Request) {
+	hub, ok := a.hub(w, req)
+	if !ok {
+		return
+	}
+	hub.Close()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminHandler) getClient(w http.ResponseWriter, req *http.Request) {
+	hub, ok := a.hub(w, req)
+	if !ok {
+		return
+	}
+	info, err := hub.Client(req.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (a *adminHandler) send(w http.ResponseWriter, req *http.Request) {
+	hub, ok := a.hub(w, req)
+	if !ok {
+		return
+	}
+	var event Event
+	if err := json.NewDecoder(req.Body).Decode(&event); err != nil || event.Name == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected an event with a name"})
+		return
+	}
+	if err := hub.SendTo(req.PathValue("id"), event); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminHandler) disconnect(w http.ResponseWriter, req *http.Request) {
+	hub, ok := a.hub(w, req)
+	if !ok {
+		return
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	// The body is optional; an empty reason is allowed.
+	json.NewDecoder(req.Body).Decode(&body)
+	if err := hub.Disconnect(req.PathValue("id"), body.Reason); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,188 @@
+package websocket_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CooperCorona/websocket"
+	"github.com/CooperCorona/websocket/wstest"
+	gorilla "github.com/gorilla/websocket"
+)
+
+// adminFixture is a Hub registered with an admin API, and a peer connected
+// to the Hub.
+type adminFixture struct {
+	hub    *websocket.Hub
+	server *wstest.Server
+	conn   *wstest.Conn
+	admin  *httptest.Server
+}
+
+func newAdminFixture(t *testing.T) *adminFixture {
+	t.Helper()
+	hub := websocket.NewHub()
+	hub.Key = "chat"
+	hubs := websocket.NewHubRegistry()
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{})
+	t.Cleanup(server.Close)
+	if err := hubs.Add(hub); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	admin := httptest.NewServer(websocket.NewAdminHandler(hubs))
+	t.Cleanup(admin.Close)
+	return &adminFixture{hub: hub, server: server, conn: conn, admin: admin}
+}
+
+// request makes a request of the admin API, returning the response's
+// status and body.
+func (f *adminFixture) request(t *testing.T, method string, path string, body string) (int, string) {
+	t.Helper()
+	request, err := http.NewRequest(method, f.admin.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	b, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.StatusCode, string(b)
+}
+
+// clientID returns the ID of the fixture's only client.
+func (f *adminFixture) clientID(t *testing.T) string {
+	t.Helper()
+	clients, err := f.hub.Clients()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 1 {
+		t.Fatalf("expected 1 client, got %d", len(clients))
+	}
+	return clients[0].ID
+}
+
+func TestAdminHandlerInspect(t *testing.T) {
+	fixture := newAdminFixture(t)
+	id := fixture.clientID(t)
+
+	status, body := fixture.request(t, http.MethodGet, "/hubs", "")
+	var hubs []websocket.HubInfo
+	if err := json.Unmarshal([]byte(body), &hubs); err != nil || status != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", status, body)
+	}
+	if len(hubs) != 1 || hubs[0].Key != "chat" || len(hubs[0].Clients) != 1 || hubs[0].Clients[0].ID != id {
+		t.Errorf("unexpected hubs %+v", hubs)
+	}
+
+	status, body = fixture.request(t, http.MethodGet, "/hubs/chat/clients/"+id, "")
+	var client websocket.ClientInfo
+	if err := json.Unmarshal([]byte(body), &client); err != nil || status != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", status, body)
+	}
+	if client.ID != id || client.RemoteAddr == "" {
+		t.Errorf("unexpected client %+v", client)
+	}
+
+	for _, path := range []string{"/hubs/missing", "/hubs/chat/clients/missing"} {
+		if status, body := fixture.request(t, http.MethodGet, path, ""); status != http.StatusNotFound {
+			t.Errorf("expected GET %s to be not found, got %d %s", path, status, body)
+		}
+	}
+}
+
+func TestAdminHandlerSend(t *testing.T) {
+	fixture := newAdminFixture(t)
+	id := fixture.clientID(t)
+
+	if status, body := fixture.request(t, http.MethodPost, "/hubs/chat/broadcast", `{"name":"announcement","data":1}`); status != http.StatusNoContent {
+		t.Fatalf("unexpected broadcast response %d %s", status, body)
+	}
+	if _, err := fixture.conn.ExpectEvent("announcement", waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := fixture.request(t, http.MethodPost, "/hubs/chat/clients/"+id+"/send", `{"name":"direct","data":2}`); status != http.StatusNoContent {
+		t.Fatalf("unexpected send response %d %s", status, body)
+	}
+	event, err := fixture.conn.ExpectEvent("direct", waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(event.Data) != "2" {
+		t.Errorf("expected data 2, got %s", event.Data)
+	}
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{"/hubs/chat/broadcast", `{"data":1}`, http.StatusBadRequest},
+		{"/hubs/chat/broadcast", `not json`, http.StatusBadRequest},
+		{"/hubs/chat/clients/" + id + "/send", `{}`, http.StatusBadRequest},
+		{"/hubs/chat/clients/missing/send", `{"name":"direct"}`, http.StatusNotFound},
+		{"/hubs/missing/broadcast", `{"name":"announcement"}`, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		if status, body := fixture.request(t, http.MethodPost, test.path, test.body); status != test.status {
+			t.Errorf("expected POST %s %s to respond %d, got %d %s", test.path, test.body, test.status, status, body)
+		}
+	}
+}
+
+// TestAdminHandlerDisconnect checks that a disconnected peer is sent its
+// reason, truncated on a UTF-8 boundary to fit in a close frame.
+func TestAdminHandlerDisconnect(t *testing.T) {
+	fixture := newAdminFixture(t)
+	id := fixture.clientID(t)
+
+	reason := strings.Repeat("é", 100)
+	body, err := json.Marshal(map[string]string{"reason": reason})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, response := fixture.request(t, http.MethodPost, "/hubs/chat/clients/"+id+"/disconnect", string(body)); status != http.StatusNoContent {
+		t.Fatalf("unexpected disconnect response %d %s", status, response)
+	}
+	if err := fixture.conn.WaitClosed(waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	closeErr := fixture.conn.CloseError()
+	if closeErr == nil || closeErr.Code != gorilla.ClosePolicyViolation {
+		t.Fatalf("expected a policy violation, got %v", closeErr)
+	}
+	// 61 two byte runes fit in the 123 bytes allowed.
+	if expected := strings.Repeat("é", 61); closeErr.Text != expected {
+		t.Errorf("expected reason %q, got %q", expected, closeErr.Text)
+	}
+	if status, _ := fixture.request(t, http.MethodPost, "/hubs/chat/clients/"+id+"/disconnect", ""); status != http.StatusNotFound {
+		t.Errorf("expected disconnecting again to be not found, got %d", status)
+	}
+}
+
+func TestAdminHandlerClose(t *testing.T) {
+	fixture := newAdminFixture(t)
+	if status, body := fixture.request(t, http.MethodPost, "/hubs/chat/close", ""); status != http.StatusNoContent {
+		t.Fatalf("unexpected close response %d %s", status, body)
+	}
+	waitHubClosed(t, fixture.hub)
+	if err := fixture.conn.WaitClosed(waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if err := fixture.hub.SendTo("anyone", websocket.Event{Name: "late"}); err != websocket.ErrHubClosed {
+		t.Errorf("expected ErrHubClosed, got %v", err)
+	}
+}
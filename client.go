@@ -1,5 +1,10 @@
 package websocket
 
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
 // Client is an object that listens for messages
 // from a Hub. A Client may only be reigstered with
 // one Hub at a time.
@@ -13,6 +18,21 @@ type Client interface {
 	Close()
 }
 
+// Identifier is implemented by Clients with a stable, unique ID. A Hub
+// identifies clients that do not implement Identifier by an ID it
+// generates when they are registered.
+type Identifier interface {
+	// ID returns the client's ID.
+	ID() string
+}
+
+// newClientID generates a random client ID.
+func newClientID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // emptyClient is a dummy client used by Hub to allow broadcasting
 // messages not originating from a client. Clients consume messages _and_
 // produce messages, but not all producers consume messages.
@@ -0,0 +1,222 @@
+package websocket
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and schedules events for a Hub and its clients. It
+// exists so tests can replace real time with a FakeClock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTicker returns a Ticker that ticks every d. d must be positive.
+	NewTicker(d time.Duration) Ticker
+	// NewTimer returns a Timer that fires once after d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Ticker delivers ticks at intervals, like time.Ticker.
+type Ticker interface {
+	// C returns the channel on which ticks are delivered.
+	C() <-chan time.Time
+	// Stop turns off the ticker.
+	Stop()
+	// Reset stops the ticker and resets its period to d.
+	Reset(d time.Duration)
+}
+
+// Timer fires once, like time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is delivered when the timer
+	// fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. Returns false if the timer had
+	// already fired or been stopped.
+	Stop() bool
+	// Reset changes the timer to fire after d. Returns true if the timer
+	// had been active.
+	Reset(d time.Duration) bool
+}
+
+// realClock is the Clock of real time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time   { return t.ticker.C }
+func (t realTicker) Stop()                 { t.ticker.Stop() }
+func (t realTicker) Reset(d time.Duration) { t.ticker.Reset(d) }
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time        { return t.timer.C }
+func (t realTimer) Stop() bool                 { return t.timer.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.timer.Reset(d) }
+
+// clock returns the Clock the Hub uses.
+func (h *Hub) clock() Clock {
+	if h.Clock == nil {
+		return realClock{}
+	}
+	return h.Clock
+}
+
+// FakeClock is a Clock whose time only changes when Advance is called, for
+// deterministic tests of timeouts. FakeClock is safe for concurrent use.
+type FakeClock struct {
+	// mu guards all fields below.
+	mu sync.Mutex
+	// changed is broadcast whenever a ticker or timer is started or
+	// stopped.
+	changed *sync.Cond
+	// now is the current time.
+	now time.Time
+	// active are the tickers and timers that have not been stopped or, for
+	// timers, fired.
+	active []*fakeTimer
+}
+
+// NewFakeClock constructs a FakeClock whose current time is now.
+func NewFakeClock(now time.Time) *FakeClock {
+	clock := &FakeClock{now: now}
+	clock.changed = sync.NewCond(&clock.mu)
+	return clock
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	timer := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	timer.Reset(d)
+	return fakeTicker{timer}
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	timer := &fakeTimer{clock: c, c: make(chan time.Time, 1), once: true}
+	timer.Reset(d)
+	return timer
+}
+
+// Advance moves the clock forward by d, firing every ticker and timer that
+// comes due on the way, in order. Like real tickers, a ticker whose
+// channel is full drops ticks.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for len(c.active) > 0 {
+		sort.SliceStable(c.active, func(i, j int) bool { return c.active[i].when.Before(c.active[j].when) })
+		timer := c.active[0]
+		if timer.when.After(end) {
+			break
+		}
+		c.now = timer.when
+		select {
+		case timer.c <- timer.when:
+		default:
+		}
+		if timer.once {
+			c.remove(timer)
+		} else {
+			timer.when = timer.when.Add(timer.period)
+		}
+	}
+	c.now = end
+}
+
+// BlockUntil blocks until at least n tickers and timers are active, so a
+// test can wait for the code under test to schedule something before
+// advancing the clock.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.active) < n {
+		c.changed.Wait()
+	}
+}
+
+// remove deactivates timer. c.mu must be held. Returns true if timer was
+// active.
+func (c *FakeClock) remove(timer *fakeTimer) bool {
+	for i, active := range c.active {
+		if active == timer {
+			c.active = append(c.active[:i], c.active[i+1:]...)
+			c.changed.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTimer is a Timer of a FakeClock, and the implementation of its
+// tickers.
+type fakeTimer struct {
+	clock *FakeClock
+	c     chan time.Time
+	// once is true for timers, which fire once, and false for tickers.
+	once bool
+	// when is the time the timer next fires. Guarded by clock.mu.
+	when time.Time
+	// period is the interval between ticks. Guarded by clock.mu.
+	period time.Duration
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := t.clock.remove(t)
+	t.when = t.clock.now.Add(d)
+	t.period = d
+	t.clock.active = append(t.clock.active, t)
+	t.clock.changed.Broadcast()
+	return wasActive
+}
+
+// fakeTicker is a Ticker of a FakeClock.
+type fakeTicker struct {
+	timer *fakeTimer
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.timer.C() }
+func (t fakeTicker) Stop()               { t.timer.Stop() }
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.timer.Reset(d)
+}
@@ -0,0 +1,106 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockTimer(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewFakeClock(start)
+	timer := clock.NewTimer(time.Second)
+
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+	clock.Advance(time.Millisecond)
+	select {
+	case fired := <-timer.C():
+		if !fired.Equal(start.Add(time.Second)) {
+			t.Errorf("expected timer to fire at %v, got %v", start.Add(time.Second), fired)
+		}
+	default:
+		t.Fatal("timer did not fire")
+	}
+	if timer.Stop() {
+		t.Error("expected Stop to report the timer had fired")
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	ticks := 0
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+		select {
+		case <-ticker.C():
+			ticks++
+		default:
+		}
+	}
+	if ticks != 3 {
+		t.Errorf("expected 3 ticks, got %d", ticks)
+	}
+	ticker.Stop()
+	clock.Advance(time.Second)
+	select {
+	case <-ticker.C():
+		t.Error("stopped ticker ticked")
+	default:
+	}
+}
+
+// waitDone fails the test if hub does not close soon.
+func waitDone(t *testing.T, hub *Hub) {
+	t.Helper()
+	select {
+	case <-hub.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("hub did not close")
+	}
+}
+
+func TestHubIdleTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	hub := NewHub()
+	hub.Clock = clock
+	hub.CloseTimeout = time.Minute
+	go hub.Run()
+	clock.BlockUntil(1)
+
+	clock.Advance(59 * time.Second)
+	if _, err := hub.Clients(); err != nil {
+		t.Fatalf("hub closed before its timeout: %v", err)
+	}
+	clock.Advance(time.Second)
+	waitDone(t, hub)
+}
+
+func TestHubIdleTimeoutDelayedByBroadcast(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	hub := NewHub()
+	hub.Clock = clock
+	hub.CloseTimeout = time.Minute
+	go hub.Run()
+	clock.BlockUntil(1)
+
+	clock.Advance(30 * time.Second)
+	hub.BroadcastAll("keep_alive", []byte(`{}`))
+	// Queries run after the broadcast, so the hub has recorded it.
+	if _, err := hub.Clients(); err != nil {
+		t.Fatal(err)
+	}
+	// The first tick finds the hub active 30 seconds ago.
+	clock.Advance(59 * time.Second)
+	if _, err := hub.Clients(); err != nil {
+		t.Fatalf("hub closed before its delayed timeout: %v", err)
+	}
+	clock.Advance(time.Minute)
+	waitDone(t, hub)
+}
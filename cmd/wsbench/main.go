@@ -0,0 +1,369 @@
+// wsbench load tests a Hub. It opens many concurrent websocket clients
+// speaking the {name, data} event protocol, broadcasts events from them at
+// a fixed rate, and reports how long connecting took, the latency of
+// deliveries, how many clients were dropped, and memory usage.
+//
+// Without -url, wsbench starts a Hub behind a local server in the same
+// process, so its memory usage includes the server's. With -url, it
+// targets a running server, which must broadcast events it receives to the
+// other clients of the same Hub and answer heartbeat events.
+//
+// Before broadcasting, every client waits for the reply to a heartbeat
+// event. The server registers a client with its Hub before reading from it,
+// so once every client has its reply, every event broadcast afterwards
+// reaches all of them.
+//
+// Usage:
+//
+//	go run ./cmd/wsbench -clients 1000 -rate 100 -size 128 -duration 30s
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CooperCorona/websocket"
+	gorilla "github.com/gorilla/websocket"
+)
+
+// benchEventName is the name of the events wsbench broadcasts.
+const benchEventName = "wsbench"
+
+// warmUpTimeout bounds how long the clients wait for their heartbeat
+// replies before broadcasting.
+const warmUpTimeout = 10 * time.Second
+
+// payload is the data of a benchmark event.
+type payload struct {
+	// Sent is the time the event was sent, in nanoseconds since the epoch.
+	Sent int64 `json:"sent"`
+	// Padding pads the payload to the requested size.
+	Padding string `json:"padding"`
+}
+
+// benchClient is one connection of the benchmark.
+type benchClient struct {
+	conn *gorilla.Conn
+	// writeLock serializes writes to conn.
+	writeLock sync.Mutex
+	// disconnected is set if the server closed the connection during the
+	// run.
+	disconnected atomic.Bool
+	// pong receives a value when the client receives a heartbeat reply.
+	pong chan struct{}
+}
+
+// results accumulate the measurements of the receiving clients.
+type results struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	malformed int
+}
+
+func (r *results) record(latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies = append(r.latencies, latency)
+}
+
+func main() {
+	url := flag.String("url", "", "websocket URL of the hub to target; if empty, a local hub is started")
+	clients := flag.Int("clients", 100, "number of concurrent clients")
+	dialConcurrency := flag.Int("dial-concurrency", 50, "number of clients connecting at once")
+	rate := flag.Float64("rate", 10, "events broadcast per second, across all clients")
+	size := flag.Int("size", 64, "size of each event's data in bytes")
+	duration := flag.Duration("duration", 10*time.Second, "how long to broadcast for")
+	drain := flag.Duration("drain", 2*time.Second, "how long to wait for deliveries after broadcasting stops")
+	flag.Parse()
+	log.SetFlags(0)
+	if *clients < 2 {
+		log.Fatal("wsbench: -clients must be at least 2, so events have recipients")
+	}
+	if *rate <= 0 {
+		log.Fatal("wsbench: -rate must be positive")
+	}
+
+	var metrics *websocket.MemoryMetrics
+	if *url == "" {
+		if messageSize, err := benchMessageSize(*size); err != nil {
+			log.Fatalf("wsbench: %v", err)
+		} else if messageSize > websocket.MaxMessageSize {
+			log.Fatalf("wsbench: events with %d bytes of data are %d byte messages, exceeding the hub's %d byte message limit", *size, messageSize, websocket.MaxMessageSize)
+		}
+		metrics = websocket.NewMemoryMetrics()
+		*url = startLocalServer(metrics)
+		fmt.Printf("started local hub at %s\n", *url)
+	}
+
+	results := &results{}
+	connected, connectTimes, err := connect(*url, *clients, *dialConcurrency, results)
+	if err != nil {
+		log.Fatalf("wsbench: %v", err)
+	}
+	fmt.Printf("connected %d clients in %v\n", len(connected), connectTimes.total.Round(time.Millisecond))
+	printPercentiles("connect time", connectTimes.durations)
+	if err := warmUp(connected, warmUpTimeout); err != nil {
+		log.Fatalf("wsbench: %v", err)
+	}
+
+	sent := broadcast(connected, *rate, *size, *duration)
+	time.Sleep(*drain)
+
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	disconnected := 0
+	for _, client := range connected {
+		if client.disconnected.Load() {
+			disconnected++
+		}
+		client.conn.Close()
+	}
+
+	results.mu.Lock()
+	defer results.mu.Unlock()
+	expected := sent * (len(connected) - 1)
+	fmt.Printf("sent %d events, expecting %d deliveries\n", sent, expected)
+	fmt.Printf("received %d deliveries (%.2f%%), %d malformed\n", len(results.latencies), percent(len(results.latencies), expected), results.malformed)
+	printPercentiles("delivery latency", results.latencies)
+	fmt.Printf("clients disconnected by the server: %d\n", disconnected)
+	if metrics != nil {
+		hubMetrics := metrics.Hubs()[""]
+		fmt.Printf("clients dropped by the hub: %d\n", hubMetrics.ClientsDropped)
+	}
+	fmt.Printf("memory: heap %s, system %s, goroutines %d\n", formatBytes(memory.HeapAlloc), formatBytes(memory.Sys), runtime.NumGoroutine())
+}
+
+// startLocalServer starts a Hub behind a server on a random local port,
+// returning its websocket URL.
+func startLocalServer(metrics websocket.Metrics) string {
+	hub := websocket.NewHub()
+	hub.Metrics = metrics
+	go hub.Run()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("wsbench: %v", err)
+	}
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		websocket.ServeWebsocket(hub, w, req, nil)
+	}))
+	return "ws://" + listener.Addr().String()
+}
+
+// connectTimes are the times taken to connect the clients.
+type connectTimes struct {
+	// total is the time taken to connect every client.
+	total time.Duration
+	// durations are the times taken to connect each client.
+	durations []time.Duration
+}
+
+// connect opens n clients to url, at most concurrency at once, each
+// recording the events it receives in results.
+func connect(url string, n int, concurrency int, results *results) ([]*benchClient, connectTimes, error) {
+	clients := make([]*benchClient, n)
+	times := connectTimes{durations: make([]time.Duration, n)}
+	errs := make(chan error, n)
+	slots := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range clients {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			dialStart := time.Now()
+			conn, _, err := gorilla.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				errs <- fmt.Errorf("connecting client %d: %w", i, err)
+				return
+			}
+			times.durations[i] = time.Since(dialStart)
+			clients[i] = &benchClient{conn: conn, pong: make(chan struct{}, 1)}
+			go clients[i].receive(results)
+		}()
+	}
+	wg.Wait()
+	times.total = time.Since(start)
+	close(errs)
+	if err := <-errs; err != nil {
+		for _, client := range clients {
+			if client != nil {
+				client.conn.Close()
+			}
+		}
+		return nil, times, err
+	}
+	return clients, times, nil
+}
+
+// receive records the latency of every benchmark event the client
+// receives, and signals pong for every heartbeat reply, until its
+// connection closes.
+func (c *benchClient) receive(results *results) {
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				c.disconnected.Store(true)
+			}
+			return
+		}
+		received := time.Now()
+		var event websocket.Event
+		var data payload
+		malformed := json.Unmarshal(message, &event) != nil
+		if !malformed && event.Name == websocket.PongEventName {
+			select {
+			case c.pong <- struct{}{}:
+			default:
+			}
+			continue
+		}
+		if malformed || json.Unmarshal(event.Data, &data) != nil {
+			results.mu.Lock()
+			results.malformed++
+			results.mu.Unlock()
+			continue
+		}
+		if event.Name == benchEventName {
+			results.record(received.Sub(time.Unix(0, data.Sent)))
+		}
+	}
+}
+
+// warmUp sends a heartbeat event from every client and waits up to timeout
+// for every reply, so that the Hub has registered every client before
+// broadcasting starts.
+func warmUp(clients []*benchClient, timeout time.Duration) error {
+	for i, client := range clients {
+		client.writeLock.Lock()
+		err := client.conn.WriteJSON(websocket.Event{Name: websocket.PingEventName})
+		client.writeLock.Unlock()
+		if err != nil {
+			return fmt.Errorf("sending a heartbeat from client %d: %w", i, err)
+		}
+	}
+	deadline := time.After(timeout)
+	for i, client := range clients {
+		select {
+		case <-client.pong:
+		case <-deadline:
+			return fmt.Errorf("client %d received no heartbeat reply within %v", i, timeout)
+		}
+	}
+	return nil
+}
+
+// benchEvent returns a benchmark event sent at sent, in nanoseconds since
+// the epoch, with size bytes of padding.
+func benchEvent(sent int64, size int) (websocket.Event, error) {
+	data, err := json.Marshal(payload{Sent: sent, Padding: strings.Repeat("x", size)})
+	if err != nil {
+		return websocket.Event{}, err
+	}
+	return websocket.Event{Name: benchEventName, Data: data}, nil
+}
+
+// benchMessageSize returns the largest message in bytes that sending a
+// benchmark event with size bytes of padding writes, including the newline
+// WriteJSON appends.
+func benchMessageSize(size int) (int, error) {
+	event, err := benchEvent(math.MaxInt64, size)
+	if err != nil {
+		return 0, err
+	}
+	message, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	return len(message) + 1, nil
+}
+
+// send sends a benchmark event with size bytes of padding.
+func (c *benchClient) send(size int) error {
+	event, err := benchEvent(time.Now().UnixNano(), size)
+	if err != nil {
+		return err
+	}
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.conn.WriteJSON(event)
+}
+
+// broadcast sends events from the clients in turn at rate per second for
+// duration, returning the number sent.
+func broadcast(clients []*benchClient, rate float64, size int, duration time.Duration) int {
+	interval := time.Duration(float64(time.Second) / rate)
+	ticker := time.NewTicker(max(interval, time.Microsecond))
+	defer ticker.Stop()
+	deadline := time.After(duration)
+	sent := 0
+	for turn := 0; ; {
+		select {
+		case <-ticker.C:
+			client := clients[turn%len(clients)]
+			turn++
+			if client.disconnected.Load() {
+				continue
+			}
+			if err := client.send(size); err != nil {
+				fmt.Fprintf(os.Stderr, "wsbench: sending: %v\n", err)
+				continue
+			}
+			sent++
+		case <-deadline:
+			return sent
+		}
+	}
+}
+
+// printPercentiles prints the percentiles of durations.
+func printPercentiles(label string, durations []time.Duration) {
+	if len(durations) == 0 {
+		fmt.Printf("%s: no samples\n", label)
+		return
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	percentile := func(p float64) time.Duration {
+		index := int(math.Ceil(p*float64(len(durations)))) - 1
+		return durations[max(index, 0)]
+	}
+	fmt.Printf("%s: p50 %v, p90 %v, p99 %v, max %v\n", label,
+		percentile(0.5), percentile(0.9), percentile(0.99), durations[len(durations)-1])
+}
+
+// percent returns n as a percentage of total.
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// formatBytes formats a number of bytes in binary units.
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exponent := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exponent])
+}
@@ -0,0 +1,368 @@
+// wsevent connects to a Hub's websocket endpoint and exchanges events in
+// the {name, data} protocol, for debugging hubs by hand or smoke testing
+// them from scripts.
+//
+// Interactively, each line typed is sent as an event, written as the event
+// name followed by optional JSON data:
+//
+//	chat.message {"text": "hello"}
+//
+// Received events are printed with the time they arrived and their data
+// pretty-printed. Events sent with an ID are acknowledged, and
+// retransmissions of them are not printed again. With -script, the lines
+// of a file are sent instead, and wsevent exits once -wait passes without
+// receiving an event, failing unless every pattern of -expect matched an
+// event received.
+//
+// Usage:
+//
+//	wsevent [-filter chat.**] ws://localhost:4000/websocket
+//	wsevent -script smoke.txt -expect 'response,chat.*' ws://localhost:4000/websocket
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CooperCorona/websocket"
+	gorilla "github.com/gorilla/websocket"
+)
+
+// patterns is a flag.Value collecting event name patterns from repeated or
+// comma-separated flags.
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(value string) error {
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			*p = append(*p, pattern)
+		}
+	}
+	return nil
+}
+
+// matchesAny returns true if name matches any of p, or p is empty.
+func (p patterns) matchesAny(name string) bool {
+	if len(p) == 0 {
+		return true
+	}
+	for _, pattern := range p {
+		if websocket.MatchEventName(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// printer writes received events and messages to an output, one at a
+// time.
+type printer struct {
+	mu  sync.Mutex
+	out io.Writer
+	// raw disables pretty-printing of event data.
+	raw bool
+}
+
+// event prints an event received at received.
+func (p *printer) event(received time.Time, event websocket.Event) {
+	data := event.Data
+	if !p.raw && len(data) > 0 {
+		var indented bytes.Buffer
+		if json.Indent(&indented, data, "  ", "  ") == nil {
+			data = indented.Bytes()
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(data) == 0 {
+		fmt.Fprintf(p.out, "%s < %s\n", received.Format("15:04:05.000"), event.Name)
+		return
+	}
+	fmt.Fprintf(p.out, "%s < %s %s\n", received.Format("15:04:05.000"), event.Name, data)
+}
+
+// printf prints a message.
+func (p *printer) printf(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, format, args...)
+}
+
+// parseLine parses a line of input of the form "name {json}" into an
+// event. The data is optional. Returns ok false for blank lines and
+// comments starting with "#".
+func parseLine(line string) (event websocket.Event, ok bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return websocket.Event{}, false, nil
+	}
+	name, data, _ := strings.Cut(line, " ")
+	data = strings.TrimSpace(data)
+	event.Name = name
+	if data != "" {
+		if !json.Valid([]byte(data)) {
+			return websocket.Event{}, false, fmt.Errorf("data of %s is not valid JSON: %s", name, data)
+		}
+		event.Data = json.RawMessage(data)
+	}
+	return event, true, nil
+}
+
+// connection is a connection to a Hub.
+type connection struct {
+	conn *gorilla.Conn
+	// writeLock serializes writes to conn, which are made by both read
+	// and the main goroutine.
+	writeLock sync.Mutex
+	// received receives the name of every event read, after it is
+	// printed. It is closed when the connection closes. It must be drained
+	// for read to keep reading and acknowledging events.
+	received chan string
+	// err is the error that closed the connection. Only read after
+	// received is closed.
+	err error
+}
+
+// read prints the events read from the connection that match filter until
+// it closes. Events sent with an ID are acknowledged, and only the first
+// delivery of each is printed.
+func (c *connection) read(printer *printer, filter patterns) {
+	defer close(c.received)
+	seen := make(map[string]bool)
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			c.err = err
+			return
+		}
+		received := time.Now()
+		var event websocket.Event
+		if err := json.Unmarshal(message, &event); err != nil {
+			printer.printf("%s ! malformed message %q: %v\n", received.Format("15:04:05.000"), message, err)
+			continue
+		}
+		if event.ID != "" {
+			// Acknowledge retransmissions too, in case an earlier
+			// acknowledgement was lost.
+			id, _ := json.Marshal(event.ID)
+			if err := c.send(websocket.Event{Name: websocket.AckEventName, Data: id}); err != nil {
+				printer.printf("%s ! acknowledging %s: %v\n", received.Format("15:04:05.000"), event.Name, err)
+			}
+			if seen[event.ID] {
+				continue
+			}
+			seen[event.ID] = true
+		}
+		if filter.matchesAny(event.Name) {
+			printer.event(received, event)
+		}
+		c.received <- event.Name
+	}
+}
+
+// send writes event to the connection.
+func (c *connection) send(event websocket.Event) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.conn.WriteJSON(event)
+}
+
+// close sends a normal closure close frame and closes the connection.
+func (c *connection) close() {
+	c.conn.WriteControl(gorilla.CloseMessage, gorilla.FormatCloseMessage(gorilla.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.conn.Close()
+}
+
+func main() {
+	var filter, expect patterns
+	flag.Var(&filter, "filter", "only print received events matching these patterns (repeatable or comma-separated)")
+	flag.Var(&expect, "expect", "in script mode, fail unless events matching each of these patterns are received (repeatable or comma-separated)")
+	script := flag.String("script", "", "send the events in this file, one per line, instead of reading standard input")
+	delay := flag.Duration("delay", 0, "in script mode, how long to wait between events")
+	wait := flag.Duration("wait", time.Second, "in script mode, how long to wait for events after the last one received")
+	raw := flag.Bool("raw", false, "print event data as received instead of pretty-printing it")
+	origin := flag.String("origin", "", "Origin header to send when connecting")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: wsevent [flags] url\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	header := http.Header{}
+	if *origin != "" {
+		header.Set("Origin", *origin)
+	}
+	conn, _, err := gorilla.DefaultDialer.Dial(flag.Arg(0), header)
+	if err != nil {
+		log.Fatalf("wsevent: connecting to %s: %v", flag.Arg(0), err)
+	}
+	c := &connection{conn: conn, received: make(chan string, 64)}
+	defer c.close()
+	printer := &printer{out: os.Stdout, raw: *raw}
+	go c.read(printer, filter)
+
+	if *script != "" {
+		if err := runScript(c, *script, *delay, *wait, expect); err != nil {
+			c.close()
+			log.Fatalf("wsevent: %v", err)
+		}
+		return
+	}
+	runInteractive(c, printer)
+}
+
+// runInteractive sends the events typed on standard input until it ends or
+// the connection closes.
+func runInteractive(c *connection, printer *printer) {
+	printer.printf("connected; type events as: name {json}\n")
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			event, ok, err := parseLine(line)
+			if err != nil {
+				printer.printf("! %v\n", err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if err := c.send(event); err != nil {
+				printer.printf("! sending: %v\n", err)
+				return
+			}
+		case _, ok := <-c.received:
+			if !ok {
+				printer.printf("connection closed: %v\n", c.err)
+				return
+			}
+		}
+	}
+}
+
+// runScript sends the events in the file at path, waiting delay between
+// them, then waits until no event has been received for wait. Returns an
+// error if the script is malformed, the connection closes, or no event
+// matching one of the patterns in expect was received.
+func runScript(c *connection, path string, delay time.Duration, wait time.Duration, expect patterns) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// missing are the patterns of expect no event has matched yet. Events
+	// are matched while the script is sent, so read never blocks on a
+	// full received channel and the hub never drops the connection for
+	// falling behind.
+	var mu sync.Mutex
+	missing := make(map[string]bool)
+	for _, pattern := range expect {
+		missing[pattern] = true
+	}
+	// activity receives a value after events are received.
+	activity := make(chan struct{}, 1)
+	// closed is closed once the connection closes and every event
+	// received has been matched.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for name := range c.received {
+			mu.Lock()
+			for pattern := range missing {
+				if websocket.MatchEventName(pattern, name) {
+					delete(missing, pattern)
+				}
+			}
+			mu.Unlock()
+			select {
+			case activity <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		event, ok, err := parseLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		if !ok {
+			continue
+		}
+		if err := c.send(event); err != nil {
+			return fmt.Errorf("%s:%d: sending: %w", path, lineNumber, err)
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	connectionClosed := false
+	for done := false; !done; {
+		select {
+		case <-activity:
+			timer.Reset(wait)
+		case <-closed:
+			connectionClosed, done = true, true
+		case <-timer.C:
+			done = true
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(missing) == 0 {
+		return nil
+	}
+	if connectionClosed {
+		return fmt.Errorf("connection closed before receiving %s: %v", missingPatterns(missing), c.err)
+	}
+	return errors.New("did not receive " + missingPatterns(missing))
+}
+
+// missingPatterns lists the patterns in missing.
+func missingPatterns(missing map[string]bool) string {
+	patterns := make([]string, 0, len(missing))
+	for pattern := range missing {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return strings.Join(patterns, ", ")
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CooperCorona/websocket"
+	"github.com/CooperCorona/websocket/wstest"
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		name  string
+		data  string
+		ok    bool
+		fails bool
+	}{
+		{"chat.message", "chat.message", "", true, false},
+		{`  chat.message {"text": "hello"}  `, "chat.message", `{"text": "hello"}`, true, false},
+		{"count 42", "count", "42", true, false},
+		{"", "", "", false, false},
+		{"   ", "", "", false, false},
+		{"# a comment", "", "", false, false},
+		{"chat.message {not json", "", "", false, true},
+	}
+	for _, test := range tests {
+		event, ok, err := parseLine(test.line)
+		if (err != nil) != test.fails {
+			t.Errorf("parseLine(%q) returned error %v", test.line, err)
+			continue
+		}
+		if ok != test.ok || event.Name != test.name || string(event.Data) != test.data {
+			t.Errorf("parseLine(%q) = %q %s %v, expected %q %s %v", test.line, event.Name, event.Data, ok, test.name, test.data, test.ok)
+		}
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	var p patterns
+	if !p.matchesAny("anything") {
+		t.Error("expected no patterns to match every name")
+	}
+	p.Set("chat.*, ,news")
+	p.Set("alerts.**")
+	if expected := (patterns{"chat.*", "news", "alerts.**"}); !slices.Equal(p, expected) {
+		t.Fatalf("expected patterns %q, got %q", expected, p)
+	}
+	if p.String() != "chat.*,news,alerts.**" {
+		t.Errorf("unexpected String %q", p.String())
+	}
+	for name, matches := range map[string]bool{
+		"chat.message":      true,
+		"chat.room.message": false,
+		"news":              true,
+		"alerts":            true,
+		"alerts.fire.north": true,
+		"weather":           false,
+	} {
+		if p.matchesAny(name) != matches {
+			t.Errorf("expected matchesAny(%q) to be %v", name, matches)
+		}
+	}
+}
+
+// burstSize is how many events the test hub broadcasts in response to a
+// burst event.
+const burstSize = 10
+
+// lineCounter is an io.Writer counting the lines written to it.
+type lineCounter struct {
+	mu    sync.Mutex
+	lines int
+}
+
+func (c *lineCounter) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines += bytes.Count(b, []byte("\n"))
+	return len(b), nil
+}
+
+func (c *lineCounter) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lines
+}
+
+// newScriptServer starts a Hub answering a burst event with burstSize
+// events and then a done event, and returns a connection to it whose
+// received channel is unbuffered, so read blocks until runScript takes
+// each event. The connection prints events to out.
+func newScriptServer(t *testing.T, out io.Writer) *connection {
+	t.Helper()
+	hub := websocket.NewHub()
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{})
+	t.Cleanup(server.Close)
+	responder := websocket.NewRouter()
+	responder.Handle("burst", func(websocket.ClientEvent) {
+		for range burstSize {
+			hub.Broadcast(responder, "burst.event", nil)
+		}
+		hub.Broadcast(responder, "done", nil)
+	})
+	go responder.Run()
+	hub.Register(responder, websocket.ClientRegistrationOptions{})
+
+	conn, _, err := gorilla.DefaultDialer.Dial(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &connection{conn: conn, received: make(chan string)}
+	t.Cleanup(c.close)
+	go c.read(&printer{out: out}, nil)
+	return c
+}
+
+// writeScript writes lines to a script file, returning its path.
+func writeScript(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestRunScript checks that events are read and matched against -expect
+// while the script is still being sent, rather than blocking the
+// connection until it is, which would make the hub drop it once enough
+// events arrive.
+func TestRunScript(t *testing.T) {
+	printed := &lineCounter{}
+	c := newScriptServer(t, printed)
+	path := writeScript(t, "# burst, then wait a second before the next event", "burst", "after {}")
+	result := make(chan error, 1)
+	go func() {
+		result <- runScript(c, path, time.Second, 200*time.Millisecond, patterns{"burst.*", "done"})
+	}()
+
+	deadline := time.Now().Add(900 * time.Millisecond)
+	for printed.count() < burstSize+1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if count := printed.count(); count < burstSize+1 {
+		t.Errorf("expected %d events to be read while the script is sent, got %d", burstSize+1, count)
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunScriptErrors(t *testing.T) {
+	tests := []struct {
+		lines  []string
+		expect patterns
+		err    string
+	}{
+		{[]string{"burst"}, patterns{"done", "missing.*"}, "did not receive missing.*"},
+		{[]string{"ok", "bad {"}, nil, "script.txt:2: data of bad is not valid JSON"},
+	}
+	for _, test := range tests {
+		c := newScriptServer(t, io.Discard)
+		err := runScript(c, writeScript(t, test.lines...), 0, 200*time.Millisecond, test.expect)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("expected an error containing %q, got %v", test.err, err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// wstypes generates TypeScript bindings for the events registered in a
+// websocket.EventTypes, so a frontend's event payload types follow the Go
+// structs they are encoded from.
+//
+// The registry must be a package-level variable of type
+// *websocket.EventTypes in an importable package (not package main),
+// typically populated by websocket.DefineEvent:
+//
+//	var Types = websocket.NewEventTypes()
+//	var ChatMessage = websocket.DefineEvent[Message](Types, "chat.message")
+//
+// wstypes builds and runs a small program importing the package within the
+// current module, which writes the bindings with EventTypes.WriteTypeScript.
+// Run it from the module containing the package, for example with go
+// generate:
+//
+//	//go:generate go run github.com/CooperCorona/websocket/cmd/wstypes -o web/events.ts . Types
+//
+// Usage:
+//
+//	wstypes [-o events.ts] [-module ./socket.mjs] package variable
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/token"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// program is the source of the program writing the bindings.
+var program = template.Must(template.New("program").Parse(`package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/CooperCorona/websocket"
+	registry {{printf "%q" .Package}}
+)
+
+func main() {
+	var types *websocket.EventTypes = registry.{{.Variable}}
+	if err := types.WriteTypeScript(os.Stdout, websocket.TypeScriptOptions{SocketModule: {{printf "%q" .SocketModule}}}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`))
+
+func main() {
+	output := flag.String("o", "", "file to write the bindings to; if empty, they are written to standard output")
+	socketModule := flag.String("module", "./socket.mjs", "import path of the module exporting Socket, relative to the bindings")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: wstypes [flags] package variable\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	variable := flag.Arg(1)
+	if !token.IsIdentifier(variable) || !token.IsExported(variable) {
+		log.Fatalf("wstypes: %q is not the name of an exported variable", variable)
+	}
+
+	importPath, err := resolve(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("wstypes: %v", err)
+	}
+	bindings, err := generate(importPath, variable, *socketModule)
+	if err != nil {
+		log.Fatalf("wstypes: %v", err)
+	}
+	if *output == "" {
+		os.Stdout.Write(bindings)
+		return
+	}
+	if err := os.WriteFile(*output, bindings, 0644); err != nil {
+		log.Fatalf("wstypes: %v", err)
+	}
+}
+
+// resolve returns the import path of the package pattern, which may be a
+// relative directory such as ".".
+func resolve(pattern string) (string, error) {
+	var stderr bytes.Buffer
+	list := exec.Command("go", "list", "-f", "{{.ImportPath}} {{.Name}}", pattern)
+	list.Stderr = &stderr
+	out, err := list.Output()
+	if err != nil {
+		return "", fmt.Errorf("finding package %s: %v\n%s", pattern, err, stderr.Bytes())
+	}
+	importPath, name, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+	if strings.Contains(name, "\n") {
+		return "", fmt.Errorf("%s matches more than one package", pattern)
+	}
+	if name == "main" {
+		return "", fmt.Errorf("%s is package main, which cannot be imported", importPath)
+	}
+	return importPath, nil
+}
+
+// generate runs a program writing the bindings of the variable of the
+// package at importPath, returning its output.
+func generate(importPath string, variable string, socketModule string) ([]byte, error) {
+	// The program must be inside the current module to import its
+	// packages.
+	dir, err := os.MkdirTemp(".", "wstypes-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	source, err := os.Create(filepath.Join(dir, "main.go"))
+	if err != nil {
+		return nil, err
+	}
+	err = program.Execute(source, struct {
+		Package      string
+		Variable     string
+		SocketModule string
+	}{importPath, variable, socketModule})
+	if closeErr := source.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var bindings bytes.Buffer
+	run := exec.Command("go", "run", "./"+filepath.ToSlash(dir))
+	run.Stdout = &bindings
+	run.Stderr = os.Stderr
+	if err := run.Run(); err != nil {
+		return nil, fmt.Errorf("generating bindings for %s.%s: %v", importPath, variable, err)
+	}
+	return bindings.Bytes(), nil
+}
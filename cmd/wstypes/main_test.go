@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerate compares the bindings generated for testdata/events with
+// testdata/events.ts.
+func TestGenerate(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	importPath, err := resolve("./testdata/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "github.com/CooperCorona/websocket/cmd/wstypes/testdata/events"; importPath != expected {
+		t.Errorf("expected import path %q, got %q", expected, importPath)
+	}
+	bindings, err := generate(importPath, "Types", "./socket.mjs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile("testdata/events.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bindings) != string(expected) {
+		t.Errorf("unexpected bindings:\n%s\nexpected\n%s", bindings, expected)
+	}
+	// The program generate runs is removed afterwards.
+	if programs, _ := filepath.Glob("wstypes-*"); len(programs) != 0 {
+		t.Errorf("expected the generated program to be removed, found %v", programs)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	tests := []struct {
+		pattern string
+		err     string
+	}{
+		{".", "is package main"},
+		{"../...", "matches more than one package"},
+		{"./testdata/missing", "finding package ./testdata/missing"},
+	}
+	for _, test := range tests {
+		if _, err := resolve(test.pattern); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("expected resolving %s to fail with %q, got %v", test.pattern, test.err, err)
+		}
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	importPath, err := resolve("./testdata/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generate(importPath, "Missing", "./socket.mjs"); err == nil || !strings.Contains(err.Error(), "generating bindings for "+importPath+".Missing") {
+		t.Errorf("expected generating the bindings of a missing variable to fail, got %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+// Package events is a registry of events for testing wstypes.
+package events
+
+import "github.com/CooperCorona/websocket"
+
+// Message is the data of a chat message.
+type Message struct {
+	Text   string   `json:"text"`
+	Author string   `json:"author"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// Types are the events of a chat.
+var Types = websocket.NewEventTypes()
+
+var (
+	ChatMessage = websocket.DefineEvent[Message](Types, "chat.message")
+	ChatTyping  = websocket.DefineEvent[bool](Types, "chat.typing")
+)
@@ -0,0 +1,201 @@
+package websocket
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+const (
+	// dashboardBufferSize is the number of observations queued for a
+	// dashboard before observations are dropped.
+	dashboardBufferSize = 1024
+
+	// dashboardSnapshotPeriod is how often a dashboard is sent the state of
+	// every client.
+	dashboardSnapshotPeriod = time.Second
+
+	// dashboardPayloadLimit is the largest event payload, in bytes, shown
+	// on a dashboard. Larger payloads are truncated on a UTF-8 boundary.
+	dashboardPayloadLimit = 4096
+)
+
+// Names of the events sent to a dashboard.
+const (
+	dashboardRegisterEvent   = "dashboard.register"
+	dashboardUnregisterEvent = "dashboard.unregister"
+	dashboardBroadcastEvent  = "dashboard.broadcast"
+	dashboardDropEvent       = "dashboard.drop"
+	dashboardIdleEvent       = "dashboard.idle"
+	dashboardCloseEvent      = "dashboard.close"
+	dashboardClientsEvent    = "dashboard.clients"
+)
+
+// dashboard is the http.Handler returned by NewDashboard.
+type dashboard struct {
+	hub *Hub
+	mux *http.ServeMux
+}
+
+// NewDashboard constructs an http.Handler serving a live debugging
+// dashboard for hub. The dashboard shows clients joining and leaving, the
+// events broadcast with their payloads, the rate of each event name, and
+// the depth of each client's queue. Mount it under a prefix ending in a
+// slash with http.StripPrefix, such as
+//
+//	http.Handle("/debug/hub/", http.StripPrefix("/debug/hub", websocket.NewDashboard(hub)))
+//
+// The dashboard exposes every event's payload, so never serve it publicly.
+func NewDashboard(hub *Hub) http.Handler {
+	d := &dashboard{hub: hub, mux: http.NewServeMux()}
+	d.mux.HandleFunc("GET /{$}", d.serveHTML)
+	d.mux.HandleFunc("GET /socket.js", func(w http.ResponseWriter, req *http.Request) {
+		ServeSocketJs(w, req)
+	})
+	d.mux.HandleFunc("GET /stream", d.serveStream)
+	return d
+}
+
+func (d *dashboard) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	d.mux.ServeHTTP(w, req)
+}
+
+func (d *dashboard) serveHTML(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// serveStream upgrades the request to a websocket and streams observations
+// of the hub to it until either closes.
+func (d *dashboard) serveStream(w http.ResponseWriter, req *http.Request) {
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return
+	}
+	observer := &dashboardObserver{events: make(chan Event, dashboardBufferSize)}
+	d.hub.Observe(observer)
+	defer d.hub.Unobserve(observer)
+
+	// The dashboard never sends anything, but reading is required to
+	// process close frames.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(dashboardSnapshotPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+	write := func(event Event) error {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		return conn.WriteJSON(event)
+	}
+	hubClosed := func() {
+		write(newDashboardEvent(dashboardCloseEvent, map[string]any{"time": time.Now()}))
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "hub closed"), time.Now().Add(writeWait))
+	}
+	if write(d.snapshot()) != nil {
+		return
+	}
+	for {
+		select {
+		case event := <-observer.events:
+			if event.Name == dashboardCloseEvent {
+				hubClosed()
+				return
+			}
+			if write(event) != nil {
+				return
+			}
+		case <-ticker.C:
+			if write(d.snapshot()) != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-d.hub.Done():
+			hubClosed()
+			return
+		}
+	}
+}
+
+// snapshot returns a dashboardClientsEvent describing every client.
+func (d *dashboard) snapshot() Event {
+	clients, err := d.hub.Clients()
+	if err != nil {
+		clients = []ClientInfo{}
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ConnectedAt.Before(clients[j].ConnectedAt) })
+	return newDashboardEvent(dashboardClientsEvent, map[string]any{"hub": d.hub.Key, "clients": clients})
+}
+
+// newDashboardEvent constructs an event sent to a dashboard.
+func newDashboardEvent(name string, data any) Event {
+	b, _ := json.Marshal(data)
+	return Event{Name: name, Data: b}
+}
+
+// dashboardObserver queues observations of a hub for a dashboard. It never
+// blocks the hub's notifications; observations are dropped if the
+// dashboard falls behind.
+type dashboardObserver struct {
+	events chan Event
+}
+
+func (o *dashboardObserver) queue(name string, data map[string]any) {
+	data["time"] = time.Now()
+	select {
+	case o.events <- newDashboardEvent(name, data):
+	default:
+	}
+}
+
+func (o *dashboardObserver) OnRegister(client Client, id string) {
+	o.queue(dashboardRegisterEvent, map[string]any{"id": id})
+}
+
+func (o *dashboardObserver) OnUnregister(client Client, id string, reason UnregisterReason) {
+	o.queue(dashboardUnregisterEvent, map[string]any{"id": id, "reason": reason.String()})
+}
+
+func (o *dashboardObserver) OnBroadcast(event ClientEvent, senderID string) {
+	payload := string(event.Event.Data)
+	truncated := len(payload) > dashboardPayloadLimit
+	if truncated {
+		payload = truncateUTF8(payload, dashboardPayloadLimit)
+	}
+	o.queue(dashboardBroadcastEvent, map[string]any{
+		"sender":    senderID,
+		"name":      event.Event.Name,
+		"size":      len(event.Event.Data),
+		"payload":   payload,
+		"truncated": truncated,
+	})
+}
+
+func (o *dashboardObserver) OnDrop(client Client, id string) {
+	o.queue(dashboardDropEvent, map[string]any{"id": id})
+}
+
+func (o *dashboardObserver) OnIdleTimeout() {
+	o.queue(dashboardIdleEvent, map[string]any{})
+}
+
+func (o *dashboardObserver) OnClose() {
+	o.queue(dashboardCloseEvent, map[string]any{})
+}
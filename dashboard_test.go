@@ -0,0 +1,107 @@
+package websocket_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CooperCorona/websocket"
+	"github.com/CooperCorona/websocket/wstest"
+	gorilla "github.com/gorilla/websocket"
+)
+
+// readDashboardEvent reads events from a dashboard stream until one named
+// name, skipping the periodic client snapshots.
+func readDashboardEvent(t *testing.T, conn *gorilla.Conn, name string) websocket.Event {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(waitTimeout))
+	for {
+		var event websocket.Event
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		if event.Name == name {
+			return event
+		}
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	hub := websocket.NewHub()
+	hub.Key = "chat"
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{})
+	defer server.Close()
+	dashboard := httptest.NewServer(websocket.NewDashboard(hub))
+	defer dashboard.Close()
+
+	for path, contentType := range map[string]string{"/": "text/html", "/socket.js": "text/javascript"} {
+		response, err := http.Get(dashboard.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK || !strings.HasPrefix(response.Header.Get("Content-Type"), contentType) {
+			t.Errorf("expected GET %s to serve %s, got %d %q", path, contentType, response.StatusCode, response.Header.Get("Content-Type"))
+		}
+	}
+
+	stream, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(dashboard.URL, "http")+"/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	// The first snapshot is sent once the dashboard is observing the hub.
+	snapshot := readDashboardEvent(t, stream, "dashboard.clients")
+	var clients struct {
+		Hub     string                 `json:"hub"`
+		Clients []websocket.ClientInfo `json:"clients"`
+	}
+	if err := json.Unmarshal(snapshot.Data, &clients); err != nil {
+		t.Fatal(err)
+	}
+	if clients.Hub != "chat" || len(clients.Clients) != 0 {
+		t.Errorf("unexpected snapshot %s", snapshot.Data)
+	}
+
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	readDashboardEvent(t, stream, "dashboard.register")
+
+	// Payloads over the limit are truncated without splitting a rune: the
+	// opening quote and 2047 two byte runes fit in 4096 bytes.
+	data, err := json.Marshal(strings.Repeat("é", 3000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.BroadcastAll("chat", data)
+	var broadcast struct {
+		Name      string `json:"name"`
+		Size      int    `json:"size"`
+		Payload   string `json:"payload"`
+		Truncated bool   `json:"truncated"`
+	}
+	if err := json.Unmarshal(readDashboardEvent(t, stream, "dashboard.broadcast").Data, &broadcast); err != nil {
+		t.Fatal(err)
+	}
+	if broadcast.Name != "chat" || broadcast.Size != len(data) || !broadcast.Truncated {
+		t.Errorf("unexpected broadcast %+v", broadcast)
+	}
+	if expected := `"` + strings.Repeat("é", 2047); broadcast.Payload != expected {
+		t.Errorf("expected the payload to be truncated to %d bytes on a rune boundary, got %d bytes ending %q", len(expected), len(broadcast.Payload), broadcast.Payload[len(broadcast.Payload)-4:])
+	}
+
+	hub.Close()
+	readDashboardEvent(t, stream, "dashboard.close")
+	_, _, err = stream.ReadMessage()
+	if !gorilla.IsCloseError(err, gorilla.CloseNormalClosure) {
+		t.Errorf("expected the stream to close normally, got %v", err)
+	}
+}
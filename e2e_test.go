@@ -0,0 +1,404 @@
+package websocket_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/CooperCorona/websocket"
+	"github.com/CooperCorona/websocket/wstest"
+	gorilla "github.com/gorilla/websocket"
+)
+
+// These tests run Hubs behind the real websocket endpoint. The first are the
+// scenarios of test/index.html, run without pressing buttons in a browser;
+// the rest cover rate limits, trace propagation and acknowledged delivery
+// end to end, which the package's own tests of those features cannot, since
+// wstest imports this package.
+
+// waitTimeout bounds every real-time wait in these tests.
+const waitTimeout = 5 * time.Second
+
+// waitHubClosed fails the test if hub does not close soon.
+func waitHubClosed(t *testing.T, hub *websocket.Hub) {
+	t.Helper()
+	select {
+	case <-hub.Done():
+	case <-time.After(waitTimeout):
+		t.Fatal("hub did not close")
+	}
+}
+
+// hubClosed returns true if hub closes within a short real-time wait.
+func hubClosed(hub *websocket.Hub) bool {
+	select {
+	case <-hub.Done():
+		return true
+	case <-time.After(20 * time.Millisecond):
+		return false
+	}
+}
+
+// advanceUntilClosed advances clock a second at a time until hub closes,
+// returning how far the clock was advanced. Fails the test if hub is still
+// open after limit.
+func advanceUntilClosed(t *testing.T, clock *websocket.FakeClock, hub *websocket.Hub, limit time.Duration) time.Duration {
+	t.Helper()
+	for advanced := time.Duration(0); advanced <= limit; advanced += time.Second {
+		if hubClosed(hub) {
+			return advanced
+		}
+		clock.Advance(time.Second)
+	}
+	t.Fatalf("hub did not close within %v", limit)
+	return 0
+}
+
+func TestConnect(t *testing.T) {
+	hub := websocket.NewHub()
+	hub.CloseOnNoClients = true
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{})
+	defer server.Close()
+
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hubClosed(hub) {
+		t.Fatal("hub closed while a client was connected")
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	waitHubClosed(t, hub)
+}
+
+func TestSend(t *testing.T) {
+	hub := websocket.NewHub()
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{
+		OnClose: func(h *websocket.Hub) { h.Close() },
+	})
+	defer server.Close()
+
+	responder := websocket.NewRouter()
+	responder.Handle("message", func(websocket.ClientEvent) {
+		hub.Broadcast(responder, "response", json.RawMessage(`{"text":"responded"}`))
+	})
+	go responder.Run()
+	responderClosed := make(chan struct{})
+	hub.Register(responder, websocket.ClientRegistrationOptions{
+		OnClose: func(*websocket.Hub) { close(responderClosed) },
+	})
+
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Send("message", map[string]string{"text": "cooper"}); err != nil {
+		t.Fatal(err)
+	}
+	event, err := conn.ExpectEvent("response", waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(event.Data, &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Text != "responded" {
+		t.Errorf("expected response %q, got %q", "responded", response.Text)
+	}
+
+	// Closing the socket closes the hub, which closes the responder.
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-responderClosed:
+	case <-time.After(waitTimeout):
+		t.Fatal("responder was not closed")
+	}
+	waitHubClosed(t, hub)
+}
+
+func TestClose(t *testing.T) {
+	hub := websocket.NewHub()
+	hub.CloseOnNoClients = true
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{})
+	defer server.Close()
+
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WaitClosed(waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if closeErr := conn.CloseError(); closeErr == nil || closeErr.Code != gorilla.CloseNormalClosure {
+		t.Errorf("expected a normal closure, got %v", closeErr)
+	}
+	waitHubClosed(t, hub)
+}
+
+func TestTimeout(t *testing.T) {
+	clock := websocket.NewFakeClock(time.Unix(0, 0))
+	hub := websocket.NewHub()
+	hub.Clock = clock
+	hub.CloseTimeout = 5 * time.Second
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{})
+	defer server.Close()
+
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(4 * time.Second)
+	if hubClosed(hub) {
+		t.Fatal("hub closed before its timeout")
+	}
+	clock.Advance(time.Second)
+	waitHubClosed(t, hub)
+	if err := conn.WaitClosed(waitTimeout); err != nil {
+		t.Fatal("socket was not closed with the hub")
+	}
+}
+
+func TestTimeoutChange(t *testing.T) {
+	clock := websocket.NewFakeClock(time.Unix(0, 0))
+	hub := websocket.NewHub()
+	hub.Clock = clock
+	hub.CloseTimeout = 5 * time.Second
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{})
+	defer server.Close()
+
+	// observer sees the keep alive event once the hub has broadcast it.
+	observer := wstest.NewClient()
+	hub.Register(observer, websocket.ClientRegistrationOptions{})
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	clock.Advance(2 * time.Second)
+	if err := hub.SetCloseTimeout(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Second)
+	if hubClosed(hub) {
+		t.Fatal("hub closed before its timeout")
+	}
+	if err := conn.Send("keep_alive", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := observer.ExpectEvent("keep_alive", waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	// The hub next checks for idleness at 5 seconds, when it has been idle
+	// for 1 second, and then every 2 seconds, so it closes by 9 seconds.
+	advanced := advanceUntilClosed(t, clock, hub, 10*time.Second)
+	if elapsed := 4*time.Second + advanced; elapsed < 6*time.Second {
+		t.Errorf("hub closed %v after starting, before the keep alive's timeout", elapsed)
+	}
+	if err := conn.WaitClosed(waitTimeout); err != nil {
+		t.Fatal("socket was not closed with the hub")
+	}
+}
+
+func TestAcknowledgedDelivery(t *testing.T) {
+	hub := websocket.NewHub()
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{
+		Ack: websocket.AckOptions{Timeout: 50 * time.Millisecond, Retries: 2},
+	})
+	defer server.Close()
+
+	sender, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	receiver, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	if err := sender.SendEvent(websocket.Event{Name: "message", Data: json.RawMessage(`"hello"`), ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	event, err := receiver.ExpectEvent("message", waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID == "" {
+		t.Error("expected the delivered event to have an ID")
+	}
+	ack, err := sender.ExpectEvent(websocket.AckEventName, waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report websocket.DeliveryReport
+	if err := json.Unmarshal(ack.Data, &report); err != nil {
+		t.Fatal(err)
+	}
+	// The sender does not receive its own event, so only the receiver
+	// acknowledges it.
+	if report.ID != "1" || !report.OK() || len(report.Delivered) != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+// clientRecorder records the clients a Hub registers.
+type clientRecorder struct {
+	websocket.BaseHubObserver
+	clients chan websocket.Client
+}
+
+func (r *clientRecorder) OnRegister(client websocket.Client, id string) {
+	r.clients <- client
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	tracer := websocket.NewMemoryTracer()
+	hub := websocket.NewHub()
+	hub.Tracer = tracer
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{})
+	defer server.Close()
+	sender, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	receiver, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if err := sender.SendEvent(websocket.Event{Name: "chat", Meta: &websocket.EventMeta{TraceParent: traceparent}}); err != nil {
+		t.Fatal(err)
+	}
+	event, err := receiver.ExpectEvent("chat", waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sender's span is the parent of receive, which is the parent of
+	// broadcast, which is the parent of deliver, which the receiver's
+	// event continues.
+	spans := map[string]websocket.RecordedSpan{}
+	for _, span := range tracer.Spans() {
+		spans[span.Name] = span
+	}
+	parent, err := websocket.ParseTraceParent(traceparent, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"websocket.receive", "websocket.broadcast", "websocket.deliver"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("expected a %s span", name)
+		}
+		if span.Parent != parent {
+			t.Errorf("expected %s to be a child of %s, got %s", name, parent.TraceParent(), span.Parent.TraceParent())
+		}
+		parent = span.SpanContext
+	}
+	if event.Meta == nil || event.Meta.TraceParent != parent.TraceParent() {
+		t.Errorf("expected the delivered event to continue %s, got %+v", parent.TraceParent(), event.Meta)
+	}
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	tests := []struct {
+		policy websocket.RateLimitPolicy
+		check  func(t *testing.T, conn *wstest.Conn)
+	}{
+		{websocket.RateLimitDrop, func(t *testing.T, conn *wstest.Conn) {
+			for _, event := range conn.Events() {
+				t.Errorf("unexpected reply %s", event.Name)
+			}
+		}},
+		{websocket.RateLimitError, func(t *testing.T, conn *wstest.Conn) {
+			event, err := conn.ExpectEvent(websocket.ErrorEventName, waitTimeout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var data websocket.ErrorEventData
+			if err := json.Unmarshal(event.Data, &data); err != nil {
+				t.Fatal(err)
+			}
+			if data.Code != websocket.ErrorCodeRateLimited || data.Event != "chat" {
+				t.Errorf("unexpected error %+v", data)
+			}
+		}},
+		{websocket.RateLimitDisconnect, func(t *testing.T, conn *wstest.Conn) {
+			if err := conn.WaitClosed(waitTimeout); err != nil {
+				t.Fatal(err)
+			}
+			if closeErr := conn.CloseError(); closeErr == nil || closeErr.Code != gorilla.ClosePolicyViolation {
+				t.Errorf("expected a policy violation, got %v", closeErr)
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			clock := websocket.NewFakeClock(time.Unix(0, 0))
+			hub := websocket.NewHub()
+			hub.Clock = clock
+			recorder := &clientRecorder{clients: make(chan websocket.Client, 2)}
+			hub.Observe(recorder)
+			server := wstest.NewServer(hub, websocket.WebsocketOptions{
+				RateLimit: &websocket.RateLimitOptions{Global: websocket.RateLimit{Rate: 1, Burst: 1}, Policy: test.policy},
+			})
+			defer server.Close()
+			observer := wstest.NewClient()
+			hub.Register(observer, websocket.ClientRegistrationOptions{})
+			conn, err := server.Dial()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			var client *websocket.WebsocketClient
+			for client == nil {
+				client, _ = (<-recorder.clients).(*websocket.WebsocketClient)
+			}
+
+			for _, name := range []string{"first", "chat"} {
+				if err := conn.Send(name, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := observer.ExpectEvent("first", waitTimeout); err != nil {
+				t.Fatal(err)
+			}
+			test.check(t, conn)
+			if test.policy != websocket.RateLimitDisconnect {
+				// The bucket refills with the Hub's clock, and events sent
+				// after the throttled one are still delivered in order.
+				clock.Advance(time.Second)
+				if err := conn.Send("last", nil); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := observer.ExpectEvent("last", waitTimeout); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, clientEvent := range observer.Events() {
+				if clientEvent.Event.Name == "chat" {
+					t.Error("throttled event was broadcast")
+				}
+			}
+			if throttled := client.ThrottledEvents(); throttled["chat"] != 1 || len(throttled) != 1 {
+				t.Errorf("unexpected throttle counts %v", throttled)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package websocket
+
+import "encoding/json"
+
+// ErrorEventName is the name of the event sent to a client when the server
+// rejects an event the client sent.
+const ErrorEventName = "$error"
+
+const (
+	// ErrorCodeRateLimited is sent when an event exceeded the client's rate limit.
+	ErrorCodeRateLimited = "rate_limited"
+	// ErrorCodeEventNotAllowed is sent when an event's name is not allowed
+	// by the Hub's EventRegistry.
+	ErrorCodeEventNotAllowed = "event_not_allowed"
+	// ErrorCodeInvalidPayload is sent when an event's data was rejected by
+	// the Hub's EventRegistry.
+	ErrorCodeInvalidPayload = "invalid_payload"
+	// ErrorCodeSubscriptionLimit is sent when a SubscribeEventName or
+	// UnsubscribeEventName event would give the client more subscriptions
+	// than the server allows.
+	ErrorCodeSubscriptionLimit = "subscription_limit"
+)
+
+// ErrorEventData is the data of an ErrorEventName event.
+type ErrorEventData struct {
+	// Code is a machine readable error code, such as ErrorCodeRateLimited.
+	Code string `json:"code"`
+	// Message is a human readable description of the error.
+	Message string `json:"message"`
+	// Event is the name of the rejected event.
+	Event string `json:"event,omitempty"`
+	// Details describe each problem with the rejected event, if known.
+	Details []string `json:"details,omitempty"`
+}
+
+// newErrorEvent constructs an ErrorEventName event.
+func newErrorEvent(data ErrorEventData) Event {
+	b, _ := json.Marshal(data)
+	return Event{Name: ErrorEventName, Data: b}
+}
@@ -0,0 +1,294 @@
+package websocket
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// eventNameSeparator separates the segments of hierarchical event
+	// names, such as "chat.room42.message".
+	eventNameSeparator = "."
+	// singleWildcard matches exactly one segment of an event name.
+	singleWildcard = "*"
+	// multiWildcard matches zero or more segments of an event name.
+	multiWildcard = "**"
+)
+
+// MatchEventName returns true if the event name eventName matches pattern.
+// Event names are hierarchical, with segments separated by ".". In a
+// pattern, a "*" segment matches exactly one segment, and a "**" segment
+// matches zero or more segments. All other segments match exactly, so
+// "chat.*.message" matches "chat.room42.message", and "chat.**" matches
+// "chat", "chat.room42" and "chat.room42.message".
+func MatchEventName(pattern string, eventName string) bool {
+	trie := newPatternTrie[struct{}]()
+	trie.add(pattern, struct{}{})
+	return trie.matchesAny(eventName)
+}
+
+// patternTrie stores values under event name patterns, and finds the
+// values of every pattern matching an event name. The cost of a match is
+// proportional to the length of the event name and the number of
+// wildcards along its path, not the number of patterns stored.
+// patternTrie is not safe for concurrent use.
+type patternTrie[V any] struct {
+	// root is the node of the empty pattern.
+	root *patternNode[V]
+	// nextSequence orders values by the time they were added.
+	nextSequence uint64
+	// patterns is how many patterns have values stored under them.
+	patterns int
+}
+
+// patternNode is a single segment of a pattern in a patternTrie.
+type patternNode[V any] struct {
+	// children are the nodes of literal segments following this one.
+	children map[string]*patternNode[V]
+	// single is the node of a "*" segment following this one.
+	single *patternNode[V]
+	// multi is the node of a "**" segment following this one.
+	multi *patternNode[V]
+	// values are the values stored under the pattern ending at this node.
+	values []sequencedValue[V]
+}
+
+// sequencedValue is a value in a patternTrie, tagged with the order it was
+// added in.
+type sequencedValue[V any] struct {
+	sequence uint64
+	value    V
+}
+
+func newPatternTrie[V any]() *patternTrie[V] {
+	return &patternTrie[V]{root: &patternNode[V]{}}
+}
+
+// node returns the node of pattern, creating it if create is true.
+// Returns nil if the node does not exist and create is false. Consecutive
+// "**" segments match the same names as one, so they share a node.
+func (t *patternTrie[V]) node(pattern string, create bool) *patternNode[V] {
+	node := t.root
+	previous := ""
+	for _, segment := range strings.Split(pattern, eventNameSeparator) {
+		if segment == multiWildcard && previous == multiWildcard {
+			continue
+		}
+		previous = segment
+		var next *patternNode[V]
+		switch segment {
+		case singleWildcard:
+			if node.single == nil && create {
+				node.single = &patternNode[V]{}
+			}
+			next = node.single
+		case multiWildcard:
+			if node.multi == nil && create {
+				node.multi = &patternNode[V]{}
+			}
+			next = node.multi
+		default:
+			if node.children[segment] == nil && create {
+				if node.children == nil {
+					node.children = make(map[string]*patternNode[V])
+				}
+				node.children[segment] = &patternNode[V]{}
+			}
+			next = node.children[segment]
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// add stores value under pattern, after any values already stored there.
+func (t *patternTrie[V]) add(pattern string, value V) {
+	node := t.node(pattern, true)
+	if len(node.values) == 0 {
+		t.patterns++
+	}
+	node.values = append(node.values, sequencedValue[V]{t.nextSequence, value})
+	t.nextSequence++
+}
+
+// set replaces the values stored under pattern with value.
+func (t *patternTrie[V]) set(pattern string, value V) {
+	node := t.node(pattern, true)
+	if len(node.values) > 0 {
+		node.values = nil
+		t.patterns--
+	}
+	t.add(pattern, value)
+}
+
+// has returns true if any value is stored under pattern.
+func (t *patternTrie[V]) has(pattern string) bool {
+	node := t.node(pattern, false)
+	return node != nil && len(node.values) > 0
+}
+
+// remove removes every value stored under pattern, pruning the nodes left
+// with neither values nor children, so that a trie whose patterns are
+// repeatedly added and removed does not grow.
+func (t *patternTrie[V]) remove(pattern string) {
+	t.root.remove(strings.Split(pattern, eventNameSeparator), "", &t.patterns)
+}
+
+// remove removes every value stored under the pattern of segments below n,
+// decrementing patterns if there were any. previous is the segment of n.
+// Returns true if n is left with neither values nor children.
+func (n *patternNode[V]) remove(segments []string, previous string, patterns *int) bool {
+	if len(segments) == 0 {
+		if len(n.values) > 0 {
+			n.values = nil
+			*patterns--
+		}
+		return n.leaf()
+	}
+	segment := segments[0]
+	if segment == multiWildcard && previous == multiWildcard {
+		// Consecutive "**" segments share a node, as in node.
+		return n.remove(segments[1:], segment, patterns)
+	}
+	switch segment {
+	case singleWildcard:
+		if n.single != nil && n.single.remove(segments[1:], segment, patterns) {
+			n.single = nil
+		}
+	case multiWildcard:
+		if n.multi != nil && n.multi.remove(segments[1:], segment, patterns) {
+			n.multi = nil
+		}
+	default:
+		if child := n.children[segment]; child != nil && child.remove(segments[1:], segment, patterns) {
+			delete(n.children, segment)
+		}
+	}
+	return n.leaf()
+}
+
+// leaf returns true if n has neither values nor children.
+func (n *patternNode[V]) leaf() bool {
+	return len(n.values) == 0 && len(n.children) == 0 && n.single == nil && n.multi == nil
+}
+
+// len returns how many patterns have values stored under them.
+func (t *patternTrie[V]) len() int {
+	return t.patterns
+}
+
+// empty returns true if no values are stored in the trie.
+func (t *patternTrie[V]) empty() bool {
+	return t.patterns == 0
+}
+
+// match calls visit with the value of every pattern matching eventName, in
+// the order the values were added. Each value is visited once, even if its
+// pattern matches in more than one way.
+func (t *patternTrie[V]) match(eventName string, visit func(V)) {
+	search := newPatternSearch[V](eventName)
+	matched := make(map[*patternNode[V]]bool)
+	search.collect(t.root, 0, matched)
+	var values []sequencedValue[V]
+	for node := range matched {
+		values = append(values, node.values...)
+	}
+	if len(matched) > 1 {
+		sort.Slice(values, func(i, j int) bool { return values[i].sequence < values[j].sequence })
+	}
+	for _, value := range values {
+		visit(value.value)
+	}
+}
+
+// matchesAny returns true if any pattern with a value matches eventName.
+func (t *patternTrie[V]) matchesAny(eventName string) bool {
+	return newPatternSearch[V](eventName).matchesAny(t.root, 0)
+}
+
+// patternSearch walks a patternTrie to find the patterns matching an event
+// name. A "**" node can be reached at the same segment along many paths,
+// so the nodes visited at each segment are remembered to explore each only
+// once. Without that, a pattern with k "**" segments would take time
+// exponential in k to match.
+type patternSearch[V any] struct {
+	// segments are the segments of the event name.
+	segments []string
+	// visited are the nodes already explored from each segment. nil until
+	// the search reaches a "**" node, since only those revisit nodes.
+	visited map[patternVisit[V]]bool
+}
+
+// patternVisit is a node explored from a segment of an event name.
+type patternVisit[V any] struct {
+	node  *patternNode[V]
+	index int
+}
+
+func newPatternSearch[V any](eventName string) *patternSearch[V] {
+	return &patternSearch[V]{segments: strings.Split(eventName, eventNameSeparator)}
+}
+
+// visit returns true the first time node is explored from segment index,
+// and false after.
+func (s *patternSearch[V]) visit(node *patternNode[V], index int) bool {
+	if s.visited == nil {
+		return true
+	}
+	key := patternVisit[V]{node, index}
+	if s.visited[key] {
+		return false
+	}
+	s.visited[key] = true
+	return true
+}
+
+// matchesAny returns true if a pattern with a value below n matches the
+// segments from index on.
+func (s *patternSearch[V]) matchesAny(n *patternNode[V], index int) bool {
+	if n == nil || !s.visit(n, index) {
+		return false
+	}
+	if n.multi != nil {
+		if s.visited == nil {
+			s.visited = make(map[patternVisit[V]]bool)
+		}
+		for i := index; i <= len(s.segments); i++ {
+			if s.matchesAny(n.multi, i) {
+				return true
+			}
+		}
+	}
+	if index == len(s.segments) {
+		return len(n.values) > 0
+	}
+	return s.matchesAny(n.children[s.segments[index]], index+1) || s.matchesAny(n.single, index+1)
+}
+
+// collect adds every node below n whose pattern matches the segments from
+// index on to matched.
+func (s *patternSearch[V]) collect(n *patternNode[V], index int, matched map[*patternNode[V]]bool) {
+	if n == nil || !s.visit(n, index) {
+		return
+	}
+	if n.multi != nil {
+		if s.visited == nil {
+			s.visited = make(map[patternVisit[V]]bool)
+		}
+		// "**" consumes any number of the remaining segments, including none.
+		for i := index; i <= len(s.segments); i++ {
+			s.collect(n.multi, i, matched)
+		}
+	}
+	if index == len(s.segments) {
+		if len(n.values) > 0 {
+			matched[n] = true
+		}
+		return
+	}
+	s.collect(n.children[s.segments[index]], index+1, matched)
+	s.collect(n.single, index+1, matched)
+}
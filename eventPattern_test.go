@@ -0,0 +1,120 @@
+package websocket
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchEventName(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		eventName string
+		matches   bool
+	}{
+		{"chat.message", "chat.message", true},
+		{"chat.message", "chat.messages", false},
+		{"chat.*.message", "chat.room42.message", true},
+		{"chat.*.message", "chat.message", false},
+		{"chat.**", "chat", true},
+		{"chat.**", "chat.room42.message", true},
+		{"**.message", "chat.room42.message", true},
+		{"chat.**.**.message", "chat.message", true},
+		{"chat.**.**.message", "chat.a.b.c.message", true},
+		{"**.**.**", "anything.at.all", true},
+		{"chat.**.*.*", "chat.a", false},
+	}
+	for _, test := range tests {
+		if matches := MatchEventName(test.pattern, test.eventName); matches != test.matches {
+			t.Errorf("MatchEventName(%q, %q) = %v, expected %v", test.pattern, test.eventName, matches, test.matches)
+		}
+	}
+}
+
+func TestPatternTrieAdversarialPattern(t *testing.T) {
+	// Each "**" may consume any number of the name's segments, so without
+	// memoization these names explore about C(60, 20) ways to fail.
+	pattern := strings.Repeat("**.a.", 20) + "b"
+	eventName := strings.Repeat("a.", 40) + "c"
+	adversarial := newPatternTrie[int]()
+	adversarial.add(pattern, 1)
+	trie := newPatternTrie[int]()
+	trie.add(pattern, 1)
+	trie.add("**", 2)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if adversarial.matchesAny(eventName) {
+			t.Errorf("expected %q not to match %q", pattern, eventName)
+		}
+		if !adversarial.matchesAny(strings.Repeat("a.", 40) + "b") {
+			t.Errorf("expected %q to match", pattern)
+		}
+		var values []int
+		trie.match(eventName, func(value int) { values = append(values, value) })
+		if len(values) != 1 || values[0] != 2 {
+			t.Errorf("expected only the %q pattern to match, got %v", "**", values)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("matching an adversarial pattern did not finish")
+	}
+}
+
+func TestDecodePatterns(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`"chat.**"`, ""},
+		{`["chat.**", "news"]`, ""},
+		{`42`, "expected a pattern"},
+		{`[` + strings.Repeat(`"a",`, maxSubscriptionPatterns) + `"a"]`, "at most 32 patterns"},
+		{`"` + strings.Repeat("**.", maxPatternSegments) + `a"`, "at most 16 segments"},
+	}
+	for _, test := range tests {
+		_, err := decodePatterns([]byte(test.data))
+		if test.err == "" && err != nil {
+			t.Errorf("decodePatterns(%s): unexpected error %v", test.data, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("decodePatterns(%s): expected an error containing %q, got %v", test.data, test.err, err)
+		}
+	}
+}
+
+// TestPatternTrieRemovePrunes checks that removing patterns prunes the
+// nodes they leave empty, so adding and removing patterns repeatedly does
+// not grow the trie, and that the trie counts its patterns.
+func TestPatternTrieRemovePrunes(t *testing.T) {
+	trie := newPatternTrie[int]()
+	trie.add("chat.room", 1)
+	for i := range 100 {
+		pattern := fmt.Sprintf("chat.*.room%d.**.**.message", i)
+		trie.add(pattern, i)
+		if trie.len() != 2 {
+			t.Fatalf("expected 2 patterns, got %d", trie.len())
+		}
+		trie.remove(pattern)
+	}
+	if trie.len() != 1 || trie.empty() {
+		t.Fatalf("expected 1 pattern, got %d", trie.len())
+	}
+	chat := trie.root.children["chat"]
+	if chat.single != nil || len(chat.children) != 1 {
+		t.Errorf("expected removed patterns to be pruned, got %+v", chat)
+	}
+	trie.remove("chat.room")
+	if !trie.empty() || !trie.root.leaf() {
+		t.Errorf("expected an empty trie, got %d patterns", trie.len())
+	}
+	// Removing a missing pattern changes nothing.
+	trie.remove("chat.missing")
+	if trie.len() != 0 {
+		t.Errorf("expected 0 patterns, got %d", trie.len())
+	}
+}
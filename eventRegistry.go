@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Validator validates the data of an event sent by a client. Returns an
+// error describing why data is malformed, or nil if it is valid.
+type Validator func(data json.RawMessage) error
+
+// ValidationError is returned by EventRegistry.Validate when an event is
+// rejected.
+type ValidationError struct {
+	// Code is ErrorCodeEventNotAllowed or ErrorCodeInvalidPayload.
+	Code string
+	// Event is the name of the rejected event.
+	Event string
+	// Details describe each problem with the event's data, if known.
+	Details []string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Code == ErrorCodeEventNotAllowed {
+		return fmt.Sprintf("event %q is not allowed", e.Event)
+	}
+	if len(e.Details) == 0 {
+		return fmt.Sprintf("event %q has an invalid payload", e.Event)
+	}
+	return fmt.Sprintf("event %q has an invalid payload: %s", e.Event, strings.Join(e.Details, "; "))
+}
+
+// errorEvent converts e into the ErrorEventName event sent to the client.
+func (e *ValidationError) errorEvent() Event {
+	return newErrorEvent(ErrorEventData{
+		Code:    e.Code,
+		Message: e.Error(),
+		Event:   e.Event,
+		Details: e.Details,
+	})
+}
+
+// EventRegistry declares which events clients may send to a Hub, and
+// optionally how to validate their data. EventRegistry is safe for
+// concurrent use.
+type EventRegistry struct {
+	// mu guards validators.
+	mu sync.RWMutex
+	// validators are the allowed event names. A nil Validator allows any
+	// data.
+	validators map[string]Validator
+}
+
+// NewEventRegistry constructs an EventRegistry that allows no events.
+func NewEventRegistry() *EventRegistry {
+	return &EventRegistry{validators: make(map[string]Validator)}
+}
+
+// Allow allows clients to send events named eventName. If validator is not
+// nil, events whose data it rejects are not broadcast.
+func (r *EventRegistry) Allow(eventName string, validator Validator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.validators[eventName] = validator
+}
+
+// AllowSchema allows clients to send events named eventName whose data
+// conforms to the JSON Schema document schema. Returns an error if schema
+// cannot be parsed.
+func (r *EventRegistry) AllowSchema(eventName string, schema []byte) error {
+	compiled, err := compileJSONSchema(schema)
+	if err != nil {
+		return err
+	}
+	r.Allow(eventName, func(data json.RawMessage) error {
+		if violations := compiled.validate(data); len(violations) > 0 {
+			return &ValidationError{Code: ErrorCodeInvalidPayload, Event: eventName, Details: violations}
+		}
+		return nil
+	})
+	return nil
+}
+
+// Disallow stops clients from sending events named eventName.
+func (r *EventRegistry) Disallow(eventName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.validators, eventName)
+}
+
+// Validate returns a *ValidationError if event is not allowed or its data
+// is invalid, or nil if it may be broadcast.
+func (r *EventRegistry) Validate(event Event) error {
+	r.mu.RLock()
+	validator, ok := r.validators[event.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return &ValidationError{Code: ErrorCodeEventNotAllowed, Event: event.Name}
+	}
+	if validator == nil {
+		return nil
+	}
+	if err := validator(event.Data); err != nil {
+		if validationErr, ok := err.(*ValidationError); ok {
+			return validationErr
+		}
+		return &ValidationError{Code: ErrorCodeInvalidPayload, Event: event.Name, Details: []string{err.Error()}}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package websocket_test
+
+import (
+	"testing"
+
+	"github.com/CooperCorona/websocket"
+	"github.com/CooperCorona/websocket/wstest"
+)
+
+func TestInvalidPayload(t *testing.T) {
+	hub := websocket.NewHub()
+	hub.Events = websocket.NewEventRegistry()
+	if err := hub.Events.AllowSchema("chat", []byte(`{"type":"object","required":["text"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{})
+	defer server.Close()
+	observer := wstest.NewClient()
+	hub.Register(observer, websocket.ClientRegistrationOptions{})
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.Send("chat", map[string]string{"body": "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	event, err := conn.ExpectEvent(websocket.ErrorEventName, waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"code":"invalid_payload","message":"event \"chat\" has an invalid payload: /: missing required property \"text\"","event":"chat","details":["/: missing required property \"text\""]}`
+	if string(event.Data) != expected {
+		t.Errorf("unexpected error event data:\n%s\nexpected\n%s", event.Data, expected)
+	}
+	if err := conn.Send("chat", map[string]string{"text": "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := observer.ExpectEvent("chat", waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if len(observer.Events()) != 1 {
+		t.Errorf("expected only the valid event to be broadcast, got %d events", len(observer.Events()))
+	}
+}
@@ -1,23 +1,95 @@
-// +build ignore
+//go:build ignore
 
+// generate compiles js/socket.ts with tsc into the builds of the browser
+// client served by the websocket package, stamping each with the SHA-256
+// of its source and of its compiled contents so tests can tell when the
+// checked-in builds are stale or were edited by hand. Run it from the
+// repository root with go generate. With -check, it writes nothing and
+// instead fails if a checked-in build differs from what tsc compiles.
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
 )
 
-func main() {
-	fileContents, err := ioutil.ReadFile("test/socket.js")
-	if err != nil {
-		log.Fatal("Could not read socket.js from expected location 'test/socket.js': %v", err)
-	}
-	outputFile := fmt.Sprintf(`package websocket
+// header begins every generated file. Its second line records the SHA-256
+// of the source the file was generated from, and its third the SHA-256 of
+// the compiled contents following the header.
+const header = "// Code generated by generate/generate.go from %s; DO NOT EDIT.\n// %s sha256: %x\n// output sha256: %x\n\n"
 
-var socketJsContents = %s%s%s
+// exportStatement matches the export statements tsc emits at the end of
+// an ES module.
+var exportStatement = regexp.MustCompile(`(?m)^export \{[^}]*\};\n?`)
 
-`, "`", fileContents, "`")
+// outputs are the builds generated from js/socket.ts.
+var outputs = []struct {
+	// path is where the build is written.
+	path string
+	// args are the arguments to tsc producing the build.
+	args []string
+	// emitted is the name of the file tsc writes.
+	emitted string
+	// transform, if not nil, rewrites the output of tsc.
+	transform func([]byte) []byte
+}{
+	// The classic script, declaring Socket as a global for <script> tags.
+	{"js/socket.js", []string{"--target", "es5", "--module", "es2015"}, "socket.js", func(compiled []byte) []byte {
+		return exportStatement.ReplaceAll(compiled, nil)
+	}},
+	// The ES module, for import statements.
+	{"js/socket.mjs", []string{"--target", "es2015", "--module", "es2015"}, "socket.js", nil},
+	// The type declarations of the ES module.
+	{"js/socket.d.ts", []string{"--declaration", "--emitDeclarationOnly"}, "socket.d.ts", nil},
+}
 
-	ioutil.WriteFile("socket.js.go", []byte(outputFile), 0666)
+func main() {
+	check := flag.Bool("check", false, "fail if a checked-in build differs from what tsc compiles, instead of writing the builds")
+	flag.Parse()
+	source, err := os.ReadFile("js/socket.ts")
+	if err != nil {
+		log.Fatalf("Could not read socket.ts from expected location 'js/socket.ts': %v", err)
+	}
+	for _, output := range outputs {
+		outDir, err := os.MkdirTemp("", "socket")
+		if err != nil {
+			log.Fatalf("Could not create a temporary directory: %v", err)
+		}
+		args := append([]string{"--lib", "dom,es2018", "--outDir", outDir}, output.args...)
+		tsc := exec.Command("tsc", append(args, "js/socket.ts")...)
+		tsc.Stdout = os.Stdout
+		tsc.Stderr = os.Stderr
+		if err := tsc.Run(); err != nil {
+			log.Fatalf("Could not compile socket.ts into %s: %v", output.path, err)
+		}
+		compiled, err := os.ReadFile(filepath.Join(outDir, output.emitted))
+		os.RemoveAll(outDir)
+		if err != nil {
+			log.Fatalf("Could not read the output of tsc: %v", err)
+		}
+		if output.transform != nil {
+			compiled = output.transform(compiled)
+		}
+		stamped := append([]byte(fmt.Sprintf(header, "socket.ts", "socket.ts", sha256.Sum256(source), sha256.Sum256(compiled))), compiled...)
+		if *check {
+			current, err := os.ReadFile(output.path)
+			if err != nil {
+				log.Fatalf("Could not read %s: %v", output.path, err)
+			}
+			if !bytes.Equal(current, stamped) {
+				log.Fatalf("%s differs from the output of tsc; run go generate", output.path)
+			}
+			continue
+		}
+		if err := os.WriteFile(output.path, stamped, 0644); err != nil {
+			log.Fatalf("Could not write %s: %v", output.path, err)
+		}
+	}
 }
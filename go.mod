@@ -2,4 +2,4 @@ module github.com/CooperCorona/websocket
 
 go 1.24.1
 
-require github.com/gorilla/websocket v1.5.3 // indirect
+require github.com/gorilla/websocket v1.5.3
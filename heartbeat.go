@@ -0,0 +1,12 @@
+package websocket
+
+const (
+	// PingEventName is the name of the heartbeat event a client sends to
+	// check that its connection is alive, since browsers cannot send
+	// websocket pings. The server replies directly with a PongEventName
+	// event carrying the same data. Neither event is broadcast.
+	PingEventName = "$ping"
+	// PongEventName is the name of the event replying to a PingEventName
+	// event.
+	PongEventName = "$pong"
+)
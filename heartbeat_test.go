@@ -0,0 +1,51 @@
+package websocket_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/CooperCorona/websocket"
+	"github.com/CooperCorona/websocket/wstest"
+)
+
+func TestHeartbeat(t *testing.T) {
+	hub := websocket.NewHub()
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{
+		RateLimit: &websocket.RateLimitOptions{Global: websocket.RateLimit{Rate: 0.001, Burst: 1}},
+	})
+	defer server.Close()
+
+	observer := wstest.NewClient()
+	hub.Register(observer, websocket.ClientRegistrationOptions{})
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Pings are answered without consuming the client's only token, so the
+	// message after them is still broadcast.
+	for i := range 3 {
+		if err := conn.Send(websocket.PingEventName, i); err != nil {
+			t.Fatal(err)
+		}
+		event, err := conn.ExpectEvent(websocket.PongEventName, waitTimeout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(event.Data) != strconv.Itoa(i) {
+			t.Errorf("expected pong data %d, got %s", i, event.Data)
+		}
+	}
+	if err := conn.Send("message", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := observer.ExpectEvent("message", waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	for _, clientEvent := range observer.Events() {
+		if name := clientEvent.Event.Name; name == websocket.PingEventName || name == websocket.PongEventName {
+			t.Errorf("heartbeat event %s was broadcast", name)
+		}
+	}
+}
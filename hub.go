@@ -1,7 +1,10 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"log/slog"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -14,6 +17,12 @@ import (
 type Event struct {
 	Name string          `json:"name"`
 	Data json.RawMessage `json:"data"`
+	// Meta is optional metadata, such as the trace context of the span that
+	// produced the event.
+	Meta *EventMeta `json:"meta,omitempty"`
+	// ID identifies an event that must be acknowledged with an
+	// AckEventName event. Empty for events that need no acknowledgement.
+	ID string `json:"id,omitempty"`
 }
 
 // ClientEvent is an event sent from a specific Client.
@@ -22,18 +31,43 @@ type ClientEvent struct {
 	Client Client
 	// Event is the event sent by the client.
 	Event Event
+	// Context carries the trace context of the event's broadcast. May be
+	// nil.
+	Context context.Context
+	// delivery tracks the acknowledgements of an event sent with
+	// BroadcastAcked. nil for other events.
+	delivery *delivery
 }
 
 // ClientRegistrationOptions configure a Client when registering it with a Hub.
 type ClientRegistrationOptions struct {
 	ReceiveSelfMessages bool
-	OnClose             func(*Hub)
+	// Metadata is arbitrary information describing the client, reported by
+	// Hub.Clients.
+	Metadata map[string]string
+	// OnClose is called after the Hub unregisters the client and closes its
+	// Send channel. Like HubObserver notifications, it is called on a
+	// separate goroutine from the Hub's Run goroutine, so it may call any of
+	// the Hub's methods.
+	OnClose func(*Hub)
+	// Filter decides whether the client receives an event. It is called on
+	// the Hub's Run goroutine before the event is queued for the client, so
+	// it must not block. If nil, the client receives every event.
+	Filter func(ClientEvent) bool
+	// Subscriptions are event name patterns (as understood by
+	// MatchEventName) restricting which events the client receives by
+	// name. If empty, the client receives events with any name.
+	// Subscriptions can be changed later with Hub.Subscribe and
+	// Hub.Unsubscribe.
+	Subscriptions []string
 }
 
 // clientData encapsulates a Client and its configuration in a Hub.
 type clientData struct {
 	// client is the Client associated with this object.
 	client Client
+	// id identifies client in logs.
+	id string
 	// receiveSelfMessages is true if client should receive messages from
 	// the Hub this client itself has sent, false if the Hub should not
 	// send this client its messages.
@@ -41,6 +75,26 @@ type clientData struct {
 	// onClose is a function executed when the Hub unregisters the client
 	// and closes the client's Send channel.
 	onClose func(*Hub)
+	// filter returns true if the client should receive an event. nil if
+	// the client receives every event.
+	filter func(ClientEvent) bool
+	// subscriptions decide which events the client receives by name.
+	subscriptions *subscriptionSet
+	// metadata is arbitrary information describing the client.
+	metadata map[string]string
+	// connectedAt is the time the client was registered.
+	connectedAt time.Time
+	// lastActivity is the time the client last broadcast an event, or the
+	// time it was registered if it never has.
+	lastActivity time.Time
+}
+
+// accepts returns true if the client should receive clientEvent.
+func (c clientData) accepts(clientEvent ClientEvent) bool {
+	if !c.subscriptions.matches(clientEvent.Event.Name) {
+		return false
+	}
+	return c.filter == nil || c.filter(clientEvent)
 }
 
 // Hub maintains the set of active clients and broadcasts messages to the
@@ -63,9 +117,27 @@ type Hub struct {
 	// unregister receives unregister requests from clients.
 	unregister chan Client
 
+	// subscriptions receives requests to change clients' subscriptions.
+	subscriptions chan subscriptionChange
+
+	// deliveries are the acknowledged events awaiting acknowledgements, by
+	// ID. Only accessed from the Run goroutine.
+	deliveries map[string]*delivery
+
+	// queries receives functions to run on the Run goroutine, which inspect
+	// or act on the registered clients.
+	queries chan func()
+
+	// done is closed when Run returns.
+	done chan struct{}
+
 	// close closes the Hub
 	close chan bool
 
+	// notifications delivers notifications to the Hub's observers, and
+	// OnClose callbacks to its clients, off the Run goroutine.
+	notifications *notificationQueue
+
 	// closeFlag is an atomic variable that is used to signal that the Hub is closing.
 	// Hubs deadlock when closed while closing, which is possible if a client's onClose
 	// callback itself closes the Hub.
@@ -85,6 +157,53 @@ type Hub struct {
 	// Must be positive.
 	CloseTimeout time.Duration
 
+	// Key identifies the Hub in metrics. Hubs without a Key share the
+	// empty label.
+	Key string
+
+	// Logger receives the Hub's log messages, and those of its
+	// WebsocketClients unless overridden by WebsocketOptions.Logger. If
+	// nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// Tracer records spans of the Hub's broadcasts and its
+	// WebsocketClients' deliveries. If nil, no spans are recorded, but
+	// trace context is still propagated.
+	Tracer Tracer
+
+	// Metrics receives measurements of the Hub and its WebsocketClients.
+	// If nil, no measurements are recorded.
+	Metrics Metrics
+
+	// ObserverBuffer is how many notifications are queued for the Hub's
+	// observers before further notifications are dropped (see
+	// HubObserver). Defaults to DefaultObserverBuffer.
+	ObserverBuffer int
+
+	// Clock tells the time and schedules the Hub's idle timeout and its
+	// WebsocketClients' pings. If nil, real time is used. Deadlines on
+	// network connections always use real time.
+	Clock Clock
+
+	// Events declares which events WebsocketClients may send to the Hub.
+	// Events that are not allowed, or whose data is invalid, are rejected
+	// with an ErrorEventName event sent back to the client. If nil, clients
+	// may send any event.
+	Events *EventRegistry
+
+	// middlewareLock guards the interceptor fields below.
+	middlewareLock sync.RWMutex
+	// inboundInterceptors are the interceptors added by UseInbound.
+	inboundInterceptors []Interceptor
+	// outboundInterceptors are the interceptors added by UseOutbound.
+	outboundInterceptors []Interceptor
+	// inbound is the composed inbound chain. nil if there are no
+	// inbound interceptors.
+	inbound Handler
+	// outbound is the composed outbound chain. nil if there are no
+	// outbound interceptors.
+	outbound Handler
+
 	// The time the last message was sent. Defaults to the time the hub
 	// began listening for messages.
 	lastMessageTimestamp time.Time
@@ -92,11 +211,15 @@ type Hub struct {
 
 // NewHub constructs a new hub with empty values.
 func NewHub() *Hub {
-	return &Hub{
+	hub := &Hub{
 		dummyClient:        &emptyClient{make(chan ClientEvent)},
 		broadcast:          make(chan ClientEvent),
 		register:           make(chan clientData),
 		unregister:         make(chan Client),
+		subscriptions:      make(chan subscriptionChange),
+		queries:            make(chan func()),
+		deliveries:         make(map[string]*delivery),
+		done:               make(chan struct{}),
 		clients:            make(map[Client]clientData),
 		close:              make(chan bool),
 		closeFlag:          0,
@@ -104,29 +227,99 @@ func NewHub() *Hub {
 		clientsHaveExisted: false,
 		CloseTimeout:       time.Minute * 10,
 	}
+	hub.notifications = newNotificationQueue(hub)
+	return hub
 }
 
 // Broadcast sends a message from a client to all registered clients.
-// Blocks until the message is broadcasted.
+// Blocks until the message is broadcasted or the hub stops running.
 func (h *Hub) Broadcast(client Client, event string, b []byte) {
-	h.broadcast <- ClientEvent{client, Event{event, b}}
+	h.BroadcastContext(context.Background(), client, event, b)
 }
 
 // Broadcast sends a message from no client to all registered clients.
-// Blocks until the message is broadcasted.
+// Blocks until the message is broadcasted or the hub stops running.
 func (h *Hub) BroadcastAll(event string, b []byte) {
-	h.broadcast <- ClientEvent{h.dummyClient, Event{event, b}}
+	h.BroadcastContext(context.Background(), nil, event, b)
+}
+
+// BroadcastContext sends a message from a client to all registered clients,
+// recording the broadcast as a span of the Hub's Tracer. The trace context
+// of ctx is propagated to recipients in the event's Meta. If client is nil,
+// the message is sent from no client, like BroadcastAll. Blocks until the
+// message is broadcasted or the hub stops running.
+func (h *Hub) BroadcastContext(ctx context.Context, client Client, event string, b []byte) {
+	h.broadcastContext(ctx, client, Event{Name: event, Data: b}, nil)
+}
+
+// broadcastContext implements BroadcastContext and BroadcastAcked. d
+// tracks the acknowledgements of event, or is nil if it needs none.
+func (h *Hub) broadcastContext(ctx context.Context, client Client, event Event, d *delivery) {
+	if client == nil {
+		client = h.dummyClient
+	}
+	ctx, span := h.tracer().Start(ctx, "websocket.broadcast")
+	defer span.End()
+	span.SetAttribute("websocket.hub", h.Key)
+	span.SetAttribute("websocket.event", event.Name)
+	event.Meta = injectMeta(ctx)
+	h.inboundHandler()(ClientEvent{
+		Client:   client,
+		Event:    event,
+		Context:  ctx,
+		delivery: d,
+	})
+}
+
+// enqueueBroadcast is the end of the inbound chain. It hands an event to
+// the Run goroutine.
+func (h *Hub) enqueueBroadcast(clientEvent ClientEvent) {
+	select {
+	case h.broadcast <- clientEvent:
+	case <-h.done:
+		if clientEvent.delivery != nil {
+			// The event was never sent, so its delivery failed.
+			// Notifications have stopped, so report it directly.
+			d := clientEvent.delivery
+			d.report.HubClosed = true
+			if d.onComplete != nil {
+				go d.onComplete(d.report)
+			}
+		}
+	}
 }
 
 // Register registers a client with the given options to receive messages.
-// Blocks until the client is registered.
+// Blocks until the client is registered or the hub stops running.
 func (h *Hub) Register(client Client, options ClientRegistrationOptions) {
-	h.register <- clientData{client, options.ReceiveSelfMessages, options.OnClose}
+	id := ""
+	if identifier, ok := client.(Identifier); ok {
+		id = identifier.ID()
+	} else {
+		id = newClientID()
+	}
+	data := clientData{
+		client:              client,
+		id:                  id,
+		receiveSelfMessages: options.ReceiveSelfMessages,
+		onClose:             options.OnClose,
+		filter:              options.Filter,
+		subscriptions:       newSubscriptionSet(options.Subscriptions),
+		metadata:            options.Metadata,
+	}
+	select {
+	case h.register <- data:
+	case <-h.done:
+	}
 }
 
-// Unregister removes a client. Blocks until the client is unregistered.
+// Unregister removes a client. Blocks until the client is unregistered or
+// the hub stops running.
 func (h *Hub) Unregister(client Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
 }
 
 // Close closes the hub and all registered clients. Does **not** block until the hub is closed.
@@ -139,68 +332,163 @@ func (h *Hub) Close() {
 	}
 }
 
-func (h *Hub) closeClient(client Client, data clientData) {
+// SetCloseTimeout changes CloseTimeout while the Hub is running. Unlike
+// assigning CloseTimeout, it is safe to call while Run is running. The new
+// timeout takes effect at the Hub's next check for idleness, which happens
+// after the old timeout. Blocks until the Hub is running. Returns
+// ErrHubClosed if the Hub has stopped running.
+func (h *Hub) SetCloseTimeout(timeout time.Duration) error {
+	return h.query(func() { h.CloseTimeout = timeout })
+}
+
+// logger returns the Logger the Hub logs to.
+func (h *Hub) logger() *slog.Logger {
+	logger := h.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger.With("hub", h.Key)
+}
+
+func (h *Hub) closeClient(client Client, data clientData, reason UnregisterReason) {
 	delete(h.clients, client)
+	h.failDeliveries(client)
 	client.Close()
+	h.metrics().ClientUnregistered(h.Key)
+	h.notifications.notify(func(observer HubObserver) { observer.OnUnregister(client, data.id, reason) })
 	if data.onClose != nil {
-		data.onClose(h)
+		h.notifications.call(func() { data.onClose(h) })
 	}
 }
 
 func (h *Hub) closeAllClients() {
 	for client, clientData := range h.clients {
-		h.closeClient(client, clientData)
+		h.closeClient(client, clientData, UnregisterHubClosed)
+	}
+}
+
+// deliver is the end of the outbound chain. It sends an event to every
+// registered client, closing clients that cannot keep up. Must only be
+// called from the Run goroutine.
+func (h *Hub) deliver(clientEvent ClientEvent) {
+	if clientEvent.delivery != nil {
+		clientEvent.delivery.clientEvent = clientEvent
+	}
+	for client, clientData := range h.clients {
+		if !clientData.receiveSelfMessages && clientEvent.Client == client {
+			// This message was sent by the current client, but the current
+			// client does not receive its own messages. Skip it.
+			continue
+		}
+		if !clientData.accepts(clientEvent) {
+			// Filtered events never take up space in the client's buffer.
+			continue
+		}
+		select {
+		case client.Send() <- clientEvent:
+			h.metrics().EventDelivered(h.Key)
+			if clientEvent.delivery != nil {
+				clientEvent.delivery.waiting[client] = clientData.id
+			}
+		default:
+			if clientEvent.delivery != nil {
+				// The client is dropped before it could acknowledge
+				// the event.
+				clientEvent.delivery.report.Failed = append(clientEvent.delivery.report.Failed, clientData.id)
+			}
+			h.dropClient(client, clientData)
+		}
+	}
+}
+
+// dropClient closes a client that is not keeping up with events. Must only
+// be called from the Run goroutine.
+func (h *Hub) dropClient(client Client, data clientData) {
+	h.logger().Warn("dropping client that is not keeping up with events", "client", data.id)
+	h.metrics().ClientDropped(h.Key)
+	h.notifications.notify(func(observer HubObserver) { observer.OnDrop(client, data.id) })
+	h.closeClient(client, data, UnregisterDropped)
+}
+
+// closeIfNoClients closes the Hub if it is configured to close when its
+// last client leaves and it has none.
+func (h *Hub) closeIfNoClients() {
+	if len(h.clients) == 0 && h.CloseOnNoClients && h.clientsHaveExisted {
+		// Use Close, which will delay until another loop can read from h.close,
+		// to ensure the atomic closeFlag is always set before closing.
+		h.Close()
 	}
 }
 
-// Run listens for register, unregister, broadcast, and close events.
+// Run listens for register, unregister, subscription, query, broadcast, and close events.
 // Blocks while the hub is running. Run on a separate goroutine
 // if you do not wish to block.
 func (h *Hub) Run() {
-	defer h.closeAllClients()
-	timeoutTicker := time.NewTicker(h.CloseTimeout)
+	logger := h.logger()
+	go h.notifications.run()
+	defer func() {
+		h.closeAllClients()
+		h.notifications.close()
+		close(h.done)
+		logger.Debug("hub closed")
+	}()
+	clock := h.clock()
+	h.lastMessageTimestamp = clock.Now()
+	timeout := h.CloseTimeout
+	timeoutTicker := clock.NewTicker(timeout)
 	defer timeoutTicker.Stop()
 	for {
 		select {
 		case clientData := <-h.register:
+			if _, ok := h.clients[clientData.client]; !ok {
+				h.metrics().ClientRegistered(h.Key)
+			}
+			clientData.connectedAt = clock.Now()
+			clientData.lastActivity = clientData.connectedAt
 			h.clients[clientData.client] = clientData
 			h.clientsHaveExisted = true
+			h.notifications.notify(func(observer HubObserver) { observer.OnRegister(clientData.client, clientData.id) })
+			logger.Debug("registered client", "client", clientData.id, "clients", len(h.clients))
 		case client := <-h.unregister:
 			if clientData, ok := h.clients[client]; ok {
-				h.closeClient(client, clientData)
-			}
-			if len(h.clients) == 0 && h.CloseOnNoClients && h.clientsHaveExisted {
-				// Use Close, which will delay until another loop can read from h.close,
-				// to ensure the atomic closeFlag is always set before closing.
-				h.Close()
+				h.closeClient(client, clientData, UnregisterRequested)
+				logger.Debug("unregistered client", "client", clientData.id, "clients", len(h.clients))
 			}
+			h.closeIfNoClients()
+		case change := <-h.subscriptions:
+			h.applySubscriptionChange(change)
+		case query := <-h.queries:
+			query()
 		case clientEvent := <-h.broadcast:
-			h.lastMessageTimestamp = time.Now()
-			for client, clientData := range h.clients {
-				if !clientData.receiveSelfMessages && clientEvent.Client == client {
-					// This message was sent by the current client, but the current
-					// client does not receive its own messages. Skip it.
-					continue
-				}
-				select {
-				case client.Send() <- clientEvent:
-				default:
-					h.closeClient(client, clientData)
-				}
+			h.lastMessageTimestamp = clock.Now()
+			h.metrics().EventBroadcast(h.Key)
+			senderID := ""
+			if sender, ok := h.clients[clientEvent.Client]; ok {
+				senderID = sender.id
+				sender.lastActivity = h.lastMessageTimestamp
+				h.clients[clientEvent.Client] = sender
 			}
-			if len(h.clients) == 0 && h.CloseOnNoClients && h.clientsHaveExisted {
-				h.Close()
+			logger.Debug("broadcasting event", "client", senderID, "event", clientEvent.Event.Name)
+			h.notifications.notify(func(observer HubObserver) { observer.OnBroadcast(clientEvent, senderID) })
+			h.outboundHandler()(clientEvent)
+			if clientEvent.delivery != nil {
+				h.track(clientEvent.delivery)
 			}
+			h.closeIfNoClients()
 		case _ = <-h.close:
 			// This only occurs when Close() has been called, guaranteeing that the
 			// closeFlag is always set before closing.
 			return
-		case _ = <-timeoutTicker.C:
-			if time.Now().Sub(h.lastMessageTimestamp) >= h.CloseTimeout {
+		case _ = <-timeoutTicker.C():
+			if clock.Now().Sub(h.lastMessageTimestamp) >= h.CloseTimeout {
+				logger.Debug("closing idle hub", "timeout", h.CloseTimeout)
+				h.notifications.notify(func(observer HubObserver) { observer.OnIdleTimeout() })
 				h.Close()
 			}
-			timeoutTicker.Stop()
-			timeoutTicker = time.NewTicker(h.CloseTimeout)
+			if h.CloseTimeout != timeout {
+				timeout = h.CloseTimeout
+				timeoutTicker.Reset(timeout)
+			}
 		}
 	}
 }
@@ -0,0 +1,174 @@
+package websocket
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// ErrHubClosed is returned when acting on a Hub that has closed.
+	ErrHubClosed = errors.New("hub is closed")
+	// ErrClientNotFound is returned when acting on a client ID that is not
+	// registered with a Hub.
+	ErrClientNotFound = errors.New("client not found")
+)
+
+// ClientInfo describes a client registered with a Hub.
+type ClientInfo struct {
+	// ID is the ID the Hub knows the client by.
+	ID string `json:"id"`
+	// Metadata is the metadata the client was registered with.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// RemoteAddr is the network address of the client's peer, if it has one.
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	// ConnectedAt is the time the client was registered.
+	ConnectedAt time.Time `json:"connectedAt"`
+	// LastActivity is the time the client last broadcast an event, or the
+	// time it was registered if it never has.
+	LastActivity time.Time `json:"lastActivity"`
+	// QueueDepth is the number of events queued for the client.
+	QueueDepth int `json:"queueDepth"`
+}
+
+// remoteAddresser is implemented by Clients connected over a network, such
+// as WebsocketClient.
+type remoteAddresser interface {
+	RemoteAddr() net.Addr
+}
+
+// closeReasoner is implemented by Clients that can tell their peer why they
+// were disconnected, such as WebsocketClient.
+type closeReasoner interface {
+	setCloseReason(code int, reason string)
+}
+
+// Done returns a channel that is closed when the Hub stops running.
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
+// query runs f on the Run goroutine, blocking until it has run. Returns
+// ErrHubClosed if the Hub stopped running before f could run.
+func (h *Hub) query(f func()) error {
+	finished := make(chan struct{})
+	select {
+	case h.queries <- func() { f(); close(finished) }:
+	case <-h.done:
+		return ErrHubClosed
+	}
+	<-finished
+	return nil
+}
+
+// info describes the client of c.
+func (c clientData) info() ClientInfo {
+	info := ClientInfo{
+		ID:           c.id,
+		Metadata:     c.metadata,
+		ConnectedAt:  c.connectedAt,
+		LastActivity: c.lastActivity,
+		QueueDepth:   len(c.client.Send()),
+	}
+	if addresser, ok := c.client.(remoteAddresser); ok {
+		info.RemoteAddr = addresser.RemoteAddr().String()
+	}
+	return info
+}
+
+// Clients describes every client registered with the Hub. Blocks until the
+// Hub can respond. Returns ErrHubClosed if the Hub has stopped running.
+func (h *Hub) Clients() ([]ClientInfo, error) {
+	var clients []ClientInfo
+	err := h.query(func() {
+		clients = make([]ClientInfo, 0, len(h.clients))
+		for _, clientData := range h.clients {
+			clients = append(clients, clientData.info())
+		}
+	})
+	return clients, err
+}
+
+// Client describes the client with the given ID. Returns ErrClientNotFound
+// if no such client is registered, or ErrHubClosed if the Hub has stopped
+// running.
+func (h *Hub) Client(id string) (ClientInfo, error) {
+	var info ClientInfo
+	found := false
+	err := h.query(func() {
+		if clientData, ok := h.findClient(id); ok {
+			info = clientData.info()
+			found = true
+		}
+	})
+	if err == nil && !found {
+		err = ErrClientNotFound
+	}
+	return info, err
+}
+
+// findClient returns the data of the client with the given ID. Must only be
+// called from the Run goroutine.
+func (h *Hub) findClient(id string) (clientData, bool) {
+	for _, clientData := range h.clients {
+		if clientData.id == id {
+			return clientData, true
+		}
+	}
+	return clientData{}, false
+}
+
+// SendTo sends event from no client to only the client with the given ID,
+// ignoring its filters and subscriptions. If the client's buffer is full,
+// it is dropped, as it would be by a broadcast. Returns ErrClientNotFound if
+// no such client is registered, or ErrHubClosed if the Hub has stopped
+// running.
+func (h *Hub) SendTo(id string, event Event) error {
+	found := false
+	err := h.query(func() {
+		clientData, ok := h.findClient(id)
+		if !ok {
+			return
+		}
+		found = true
+		select {
+		case clientData.client.Send() <- ClientEvent{Client: h.dummyClient, Event: event}:
+			h.metrics().EventDelivered(h.Key)
+		default:
+			h.dropClient(clientData.client, clientData)
+			h.closeIfNoClients()
+		}
+	})
+	if err == nil && !found {
+		err = ErrClientNotFound
+	}
+	return err
+}
+
+// Disconnect unregisters and closes the client with the given ID. If the
+// client is a WebsocketClient, its peer is sent a policy violation close
+// frame with reason, truncated to the 123 bytes a close frame allows.
+// Returns ErrClientNotFound if no such client is registered, or
+// ErrHubClosed if the Hub has stopped running.
+func (h *Hub) Disconnect(id string, reason string) error {
+	found := false
+	err := h.query(func() {
+		clientData, ok := h.findClient(id)
+		if !ok {
+			return
+		}
+		found = true
+		if reasoner, ok := clientData.client.(closeReasoner); ok {
+			reasoner.setCloseReason(websocket.ClosePolicyViolation, reason)
+		}
+		h.logger().Info("disconnecting client", "client", id, "reason", reason)
+		h.closeClient(clientData.client, clientData, UnregisterDisconnected)
+		h.closeIfNoClients()
+	})
+	if err == nil && !found {
+		err = ErrClientNotFound
+	}
+	return err
+}
@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// HubRegistry is a set of Hubs indexed by Key, such as the hubs of every
+// active game or chat room. Hubs are removed from the registry when they
+// close. HubRegistry is safe for concurrent use.
+type HubRegistry struct {
+	// mu guards hubs.
+	mu sync.RWMutex
+	// hubs are the registered hubs, keyed by Key.
+	hubs map[string]*Hub
+}
+
+// NewHubRegistry constructs an empty HubRegistry.
+func NewHubRegistry() *HubRegistry {
+	return &HubRegistry{hubs: make(map[string]*Hub)}
+}
+
+// Add adds hub to the registry under its Key. Does nothing if hub is
+// already registered. Returns an error if another hub with the same Key is
+// registered, or ErrHubClosed if hub has closed.
+func (r *HubRegistry) Add(hub *Hub) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.hubs[hub.Key]; ok {
+		if existing == hub {
+			return nil
+		}
+		return fmt.Errorf("a hub with key %q is already registered", hub.Key)
+	}
+	// The observer is added before hub is, so that hub is removed even if
+	// it closes in between. Removal waits for mu, so it follows the add.
+	if !hub.observe(&registryObserver{registry: r, hub: hub}) {
+		return ErrHubClosed
+	}
+	r.hubs[hub.Key] = hub
+	return nil
+}
+
+// Remove removes hub from the registry.
+func (r *HubRegistry) Remove(hub *Hub) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.hubs[hub.Key] == hub {
+		delete(r.hubs, hub.Key)
+	}
+}
+
+// Get returns the hub registered under key.
+func (r *HubRegistry) Get(key string) (*Hub, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	hub, ok := r.hubs[key]
+	return hub, ok
+}
+
+// Hubs returns the registered hubs, sorted by Key.
+func (r *HubRegistry) Hubs() []*Hub {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	hubs := make([]*Hub, 0, len(r.hubs))
+	for _, hub := range r.hubs {
+		hubs = append(hubs, hub)
+	}
+	sort.Slice(hubs, func(i, j int) bool { return hubs[i].Key < hubs[j].Key })
+	return hubs
+}
+
+// registryObserver removes a hub from a HubRegistry when it closes.
+type registryObserver struct {
+	BaseHubObserver
+	registry *HubRegistry
+	hub      *Hub
+}
+
+func (o *registryObserver) OnClose() {
+	o.registry.Remove(o.hub)
+}
@@ -0,0 +1,58 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHubRegistry(t *testing.T) {
+	registry := NewHubRegistry()
+	hub := NewHub()
+	hub.Key = "chat"
+	go hub.Run()
+
+	for range 2 {
+		if err := registry.Add(hub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if observers := len(hub.notifications.observers); observers != 1 {
+		t.Errorf("expected adding a hub twice to observe it once, got %d observers", observers)
+	}
+	other := NewHub()
+	other.Key = "chat"
+	if err := registry.Add(other); err == nil {
+		t.Error("expected adding another hub with the same key to fail")
+	}
+	if registered, ok := registry.Get("chat"); !ok || registered != hub {
+		t.Errorf("expected the first hub to stay registered, got %v", registered)
+	}
+
+	hub.Close()
+	<-hub.Done()
+	deadline := time.Now().Add(time.Second)
+	for len(registry.Hubs()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := registry.Get("chat"); ok {
+		t.Error("expected the closed hub to be removed")
+	}
+	if err := registry.Add(hub); err != ErrHubClosed {
+		t.Errorf("expected ErrHubClosed, got %v", err)
+	}
+}
+
+// TestHubRegistryAddClosing checks that a hub that has stopped notifying
+// its observers, but whose Done channel is not yet closed, is not added,
+// since it would never be removed.
+func TestHubRegistryAddClosing(t *testing.T) {
+	registry := NewHubRegistry()
+	hub := NewHub()
+	hub.notifications.close()
+	if err := registry.Add(hub); err != ErrHubClosed {
+		t.Errorf("expected ErrHubClosed, got %v", err)
+	}
+	if len(registry.Hubs()) != 0 {
+		t.Error("expected the closing hub not to be registered")
+	}
+}
@@ -0,0 +1,143 @@
+package websocket
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// propertyClient is a Client for property tests. Closing it twice, or the
+// Hub sending to it after closing it, panics.
+type propertyClient struct {
+	send chan ClientEvent
+	// closes counts calls of Close.
+	closes atomic.Int32
+	// onCloses counts calls of its registration's OnClose.
+	onCloses atomic.Int32
+}
+
+func newPropertyClient(buffer int, drain bool) *propertyClient {
+	client := &propertyClient{send: make(chan ClientEvent, buffer)}
+	if drain {
+		go func() {
+			for range client.send {
+			}
+		}()
+	}
+	return client
+}
+
+func (c *propertyClient) Send() chan<- ClientEvent {
+	return c.send
+}
+
+func (c *propertyClient) Close() {
+	c.closes.Add(1)
+	close(c.send)
+}
+
+// propertyObserver records which clients a Hub registered.
+type propertyObserver struct {
+	BaseHubObserver
+	mu         sync.Mutex
+	registered map[Client]bool
+	closes     int
+	closed     chan struct{}
+}
+
+func (o *propertyObserver) OnRegister(client Client, id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.registered[client] = true
+}
+
+func (o *propertyObserver) OnClose() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closes++
+	close(o.closed)
+}
+
+// runHubSequence runs a random sequence of operations on a Hub, returning
+// an error describing any broken invariant.
+func runHubSequence(seed int64) error {
+	random := rand.New(rand.NewSource(seed))
+	hub := NewHub()
+	hub.CloseOnNoClients = random.Intn(2) == 0
+	observer := &propertyObserver{registered: make(map[Client]bool), closed: make(chan struct{})}
+	hub.Observe(observer)
+	go hub.Run()
+
+	var clients []*propertyClient
+	for i, operations := 0, random.Intn(50); i < operations; i++ {
+		switch operation := random.Intn(10); {
+		case operation < 3 || len(clients) == 0:
+			client := newPropertyClient(random.Intn(4), random.Intn(4) != 0)
+			clients = append(clients, client)
+			hub.Register(client, ClientRegistrationOptions{
+				ReceiveSelfMessages: random.Intn(2) == 0,
+				OnClose:             func(*Hub) { client.onCloses.Add(1) },
+			})
+		case operation < 5:
+			hub.Unregister(clients[random.Intn(len(clients))])
+		case operation < 9:
+			var sender Client
+			if random.Intn(3) != 0 {
+				sender = clients[random.Intn(len(clients))]
+			}
+			hub.Broadcast(sender, "event", []byte(`{}`))
+		default:
+			hub.Close()
+		}
+	}
+	hub.Close()
+	<-hub.Done()
+	// OnClose is the last notification, so every OnClose callback of a
+	// client has been called once the observer's has.
+	<-observer.closed
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	if observer.closes != 1 {
+		return fmt.Errorf("observer's OnClose called %d times", observer.closes)
+	}
+	for i, client := range clients {
+		expected := int32(0)
+		if observer.registered[client] {
+			expected = 1
+		}
+		if closes := client.closes.Load(); closes != expected {
+			return fmt.Errorf("client %d closed %d times, expected %d", i, closes, expected)
+		}
+		if onCloses := client.onCloses.Load(); onCloses != expected {
+			return fmt.Errorf("client %d's OnClose called %d times, expected %d", i, onCloses, expected)
+		}
+	}
+	return nil
+}
+
+// TestHubProperties runs random sequences of register, unregister,
+// broadcast and close operations, checking that the Hub never sends to a
+// closed client (which panics), closes every registered client and calls
+// its OnClose exactly once, and never deadlocks.
+func TestHubProperties(t *testing.T) {
+	sequences := 500
+	if testing.Short() {
+		sequences = 50
+	}
+	for seed := int64(0); seed < int64(sequences); seed++ {
+		result := make(chan error, 1)
+		go func() { result <- runHubSequence(seed) }()
+		select {
+		case err := <-result:
+			if err != nil {
+				t.Fatalf("seed %d: %v", seed, err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("seed %d: deadlocked", seed)
+		}
+	}
+}
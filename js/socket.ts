@@ -0,0 +1,811 @@
+type WebSocketEvent = Event;//Event | CloseEvent | MessageEvent;
+export type SocketCallback = (socket:Socket, event:WebSocketEvent) => void;
+export type SocketEvent<T = any> = { name:string, data:T, id?:string }
+export type EventCallback<T = any> = (socket:Socket, data:T) => void;
+export type AnyEventCallback = (socket:Socket, event:SocketEvent) => void;
+/**
+ * A message received by a Socket that is not an event. text is the message,
+ * and error describes why it could not be parsed.
+ */
+export type SocketError = { text:string, error:any }
+export type ErrorCallback = (socket:Socket, error:SocketError) => void;
+/**
+ * How a Socket's connection closed.
+ */
+export type SocketClose = { code:number, reason:string, wasClean:boolean }
+/**
+ * The outcome of sending an event with sendAcked. delivered and failed are
+ * the IDs of the clients that did and did not acknowledge the event.
+ * hubClosed is true if the hub had stopped before the event was sent.
+ */
+export type DeliveryReport = { id:string, delivered:string[], failed:string[], hubClosed?:boolean }
+export type DisconnectCallback = (socket:Socket, close:SocketClose) => void;
+export type LatencyCallback = (socket:Socket, latency:number) => void;
+export type HeartbeatOptions = {
+    /**
+     * How many milliseconds to wait between pings. Defaults to 25000.
+     */
+    interval?:number
+    /**
+     * How many milliseconds to wait for a pong before the connection is
+     * considered dead. Defaults to 10000.
+     */
+    timeout?:number
+}
+export type ReconnectOptions = {
+    /**
+     * How many milliseconds to wait before the first attempt to reconnect,
+     * doubling after each failed attempt. Defaults to 1000.
+     */
+    delay?:number
+    /**
+     * The most milliseconds to wait between attempts. Defaults to 30000.
+     */
+    maxDelay?:number
+    /**
+     * How many consecutive attempts to make before giving up. Unlimited if
+     * undefined.
+     */
+    attempts?:number
+}
+export type SocketOptions = {
+    /**
+     * Sends heartbeat events to detect connections that died without
+     * closing. Disabled if undefined.
+     */
+    heartbeat?:HeartbeatOptions
+    /**
+     * Reconnects when the connection is lost, until close is called.
+     * Disabled if undefined.
+     */
+    reconnect?:ReconnectOptions
+}
+export type NextOptions = {
+    /**
+     * How many milliseconds to wait for the event before rejecting. Waits
+     * indefinitely if undefined.
+     */
+    timeout?:number
+}
+
+/**
+ * The names of the heartbeat events, which the server answers without
+ * broadcasting.
+ */
+const PING_EVENT = "$ping";
+const PONG_EVENT = "$pong";
+
+/**
+ * The name of the event acknowledging an event sent with an ID.
+ */
+const ACK_EVENT = "$ack";
+
+/**
+ * How many IDs of received events a Socket remembers, to ignore
+ * retransmissions of them.
+ */
+const SEEN_IDS_LIMIT = 1000;
+
+/**
+ * A callback registered with a Socket. any is true for callbacks registered
+ * with onAny, which receive the whole event instead of its data.
+ */
+type Listener = { pattern:string, callback:Function, once:boolean, any:boolean, sequence:number }
+
+/**
+ * Splits pattern into segments, merging consecutive "**" segments, which
+ * match the same names as one.
+ */
+function patternSegments(pattern:string):string[] {
+    return pattern.split(".").filter((segment, i, segments) => !(segment == "**" && i > 0 && segments[i - 1] == "**"));
+}
+
+/**
+ * Stores values under hierarchical event name patterns such as
+ * "chat.*.message". A "*" segment matches exactly one segment of an event
+ * name, and a "**" segment matches zero or more segments.
+ */
+class EventTrie<T> {
+
+    private static nextID = 0;
+
+    private id = EventTrie.nextID++;
+    private children:{ [segment:string]:EventTrie<T> } = {};
+    private single:EventTrie<T>|null = null;
+    private multi:EventTrie<T>|null = null;
+    private values:T[] = [];
+
+    add(pattern:string, value:T) {
+        let node:EventTrie<T> = this;
+        for (const segment of patternSegments(pattern)) {
+            node = node._child(segment);
+        }
+        node.values.push(value);
+    }
+
+    /**
+     * Removes the values stored under pattern for which remove returns true.
+     */
+    remove(pattern:string, remove:(value:T) => boolean) {
+        let node:EventTrie<T>|null = this;
+        for (const segment of patternSegments(pattern)) {
+            if (segment == "*") {
+                node = node.single;
+            } else if (segment == "**") {
+                node = node.multi;
+            } else if (Object.prototype.hasOwnProperty.call(node.children, segment)) {
+                node = node.children[segment];
+            } else {
+                node = null;
+            }
+            if (node == null) {
+                return;
+            }
+        }
+        node.values = node.values.filter(value => !remove(value));
+    }
+
+    match(eventName:string):T[] {
+        const matched:EventTrie<T>[] = [];
+        this._collect(eventName.split("."), 0, {}, matched);
+        let values:T[] = [];
+        for (const node of matched) {
+            values = values.concat(node.values);
+        }
+        return values;
+    }
+
+    private _child(segment:string):EventTrie<T> {
+        if (segment == "*") {
+            return this.single || (this.single = new EventTrie<T>());
+        } else if (segment == "**") {
+            return this.multi || (this.multi = new EventTrie<T>());
+        }
+        if (!Object.prototype.hasOwnProperty.call(this.children, segment)) {
+            this.children[segment] = new EventTrie<T>();
+        }
+        return this.children[segment];
+    }
+
+    /**
+     * Adds every node below this one whose pattern matches segments from
+     * index on to matched. visited holds the nodes already explored from
+     * each index, so a node reachable along many "**" paths is explored
+     * once instead of exponentially many times.
+     */
+    private _collect(segments:string[], index:number, visited:{ [key:string]:boolean }, matched:EventTrie<T>[]) {
+        const key = this.id + ":" + index;
+        if (visited[key]) {
+            return;
+        }
+        visited[key] = true;
+        if (this.multi != null) {
+            for (let i = index; i <= segments.length; i++) {
+                this.multi._collect(segments, i, visited, matched);
+            }
+        }
+        if (index == segments.length) {
+            if (this.values.length > 0) {
+                matched.push(this);
+            }
+            return;
+        }
+        if (Object.prototype.hasOwnProperty.call(this.children, segments[index])) {
+            this.children[segments[index]]._collect(segments, index + 1, visited, matched);
+        }
+        if (this.single != null) {
+            this.single._collect(segments, index + 1, visited, matched);
+        }
+    }
+}
+
+/**
+ * An async iterator over the events pushed to it. It ends when end is
+ * called, or when the loop iterating it exits, after which it calls onEnd.
+ */
+class EventIterator<T> {
+
+    onEnd:() => void = () => {};
+    private buffered:SocketEvent<T>[] = [];
+    private waiting:((result:IteratorResult<SocketEvent<T>>) => void)[] = [];
+    private ended = false;
+
+    push(event:SocketEvent<T>) {
+        if (this.ended) {
+            return;
+        }
+        const resolve = this.waiting.shift();
+        if (resolve !== undefined) {
+            resolve({ value: event, done: false });
+        } else {
+            this.buffered.push(event);
+        }
+    }
+
+    /**
+     * Ends the iterator once the events already pushed have been iterated.
+     */
+    end() {
+        if (this.ended) {
+            return;
+        }
+        this.ended = true;
+        this.onEnd();
+        for (const resolve of this.waiting) {
+            resolve({ value: undefined, done: true });
+        }
+        this.waiting = [];
+    }
+
+    next():Promise<IteratorResult<SocketEvent<T>>> {
+        const event = this.buffered.shift();
+        if (event !== undefined) {
+            return Promise.resolve({ value: event, done: false });
+        }
+        if (this.ended) {
+            return Promise.resolve({ value: undefined, done: true });
+        }
+        return new Promise<IteratorResult<SocketEvent<T>>>(resolve => {
+            this.waiting.push(resolve);
+        });
+    }
+
+    return():Promise<IteratorResult<SocketEvent<T>>> {
+        this.buffered = [];
+        this.end();
+        return Promise.resolve({ value: undefined, done: true });
+    }
+
+    [Symbol.asyncIterator]() {
+        return this;
+    }
+}
+
+/**
+ * Returns the error rejecting promises waiting on a socket that closed.
+ */
+function closedError(close:SocketClose):Error {
+    return new Error("socket closed with code " + close.code + (close.reason ? ": " + close.reason : ""));
+}
+
+export class Socket {
+
+    public static STATE_CONNECTING = 0;
+    public static STATE_OPEN = 1;
+    public static STATE_CLOSING = 2;
+    public static STATE_CLOSED = 3;
+
+    private path:string
+    private options:SocketOptions
+    private webSocket:WebSocket
+    // live is the WebSocket whose events are handled, or null while
+    // disconnected.
+    private live:WebSocket|null = null
+    private listeners:EventTrie<Listener>
+    private errorCallbacks:ErrorCallback[] = []
+    private connectCallbacks:SocketCallback[] = []
+    private messageCallbacks:SocketCallback[] = []
+    private disconnectCallbacks:DisconnectCallback[] = []
+    private latencyCallbacks:LatencyCallback[] = []
+    private sequence = 0
+    private connections = 0
+    private openEvent:Event|null = null
+    private closeEvent:SocketClose|null = null
+    private closeCallbacks:((close:SocketClose) => void)[] = []
+    private closing = false
+    private reconnectAttempts = 0
+    private reconnectTimer:ReturnType<typeof setTimeout>|null = null
+    private subscriptionEvents:SocketEvent<string[]>[] = []
+    private heartbeatTimer:ReturnType<typeof setInterval>|null = null
+    private pongTimer:ReturnType<typeof setTimeout>|null = null
+    private pendingPing:{ id:number, sent:number }|null = null
+    private pingID = 0
+    private lastLatency:number|null = null
+    private ackID = 0
+    private pendingAcks:{ [id:string]:{ resolve:(report:DeliveryReport) => void, reject:(error:Error) => void } } = {}
+    private seenIDs:{ [id:string]:boolean } = {}
+    private seenOrder:string[] = []
+
+    constructor(path:string, options?:SocketOptions) {
+        this.path = path;
+        this.options = options || {};
+        this.listeners = new EventTrie<Listener>();
+        this.webSocket = this._connect();
+    }
+
+    /**
+     * Calls callback every time the socket connects, starting immediately
+     * if it is connected.
+     */
+    onConnect(callback:SocketCallback) {
+        this.connectCallbacks.push(callback);
+        if (this.openEvent != null) {
+            callback(this, this.openEvent);
+        }
+    }
+
+    /**
+     * Calls callback every time the connection is lost, including before
+     * reconnecting.
+     */
+    onDisconnect(callback:DisconnectCallback) {
+        this.disconnectCallbacks.push(callback);
+    }
+
+    onMessage(callback:SocketCallback) {
+        this.messageCallbacks.push(callback);
+    }
+
+    /**
+     * Calls callback with the round trip time in milliseconds of every
+     * heartbeat.
+     */
+    onLatency(callback:LatencyCallback) {
+        this.latencyCallbacks.push(callback);
+    }
+
+    /**
+     * Returns the round trip time in milliseconds of the last heartbeat, or
+     * null if none has been answered.
+     */
+    latency():number|null {
+        return this.lastLatency;
+    }
+
+    /**
+     * Resolves once the socket connects, or rejects if it has closed or
+     * closes first.
+     */
+    connected():Promise<void> {
+        return new Promise<void>((resolve, reject) => {
+            if (this.closeEvent != null) {
+                reject(closedError(this.closeEvent));
+                return;
+            }
+            if (this.openEvent != null) {
+                resolve();
+                return;
+            }
+            const removeCloseCallback = this._onClose(close => reject(closedError(close)));
+            const callback = () => {
+                this.connectCallbacks = this.connectCallbacks.filter(other => other !== callback);
+                removeCloseCallback();
+                resolve();
+            };
+            this.connectCallbacks.push(callback);
+        });
+    }
+
+    /**
+     * Resolves with how the socket closed once it closes and will not
+     * reconnect.
+     */
+    closed():Promise<SocketClose> {
+        return new Promise<SocketClose>(resolve => {
+            if (this.closeEvent != null) {
+                resolve(this.closeEvent);
+                return;
+            }
+            this._onClose(resolve);
+        });
+    }
+
+    /**
+     * Resolves with the data of the next event whose name matches
+     * eventName, as understood by on. Rejects if the socket closes or
+     * options.timeout passes first.
+     */
+    next<T = any>(eventName:string, options?:NextOptions):Promise<T> {
+        return new Promise<T>((resolve, reject) => {
+            if (this.closeEvent != null) {
+                reject(closedError(this.closeEvent));
+                return;
+            }
+            let timer:ReturnType<typeof setTimeout>|null = null;
+            const callback = (socket:Socket, data:T) => {
+                finish();
+                resolve(data);
+            };
+            const removeCloseCallback = this._onClose(close => {
+                finish();
+                reject(closedError(close));
+            });
+            const finish = () => {
+                this.off(eventName, callback);
+                removeCloseCallback();
+                if (timer != null) {
+                    clearTimeout(timer);
+                }
+            };
+            if (options !== undefined && options.timeout !== undefined) {
+                timer = setTimeout(() => {
+                    finish();
+                    reject(new Error("timed out waiting for " + eventName));
+                }, options.timeout);
+            }
+            this.once(eventName, callback);
+        });
+    }
+
+    /**
+     * Returns an async iterator over the events whose names match
+     * eventName, as understood by on, from now until the socket closes:
+     *
+     *     for await (const event of socket.events("chat.*")) { ... }
+     *
+     * Events received while the loop body runs are buffered.
+     */
+    events<T = any>(eventName:string):AsyncIterableIterator<SocketEvent<T>> {
+        const iterator = new EventIterator<T>();
+        const callback = (socket:Socket, event:SocketEvent<T>) => iterator.push(event);
+        this._addListener(eventName, callback, false, true);
+        const removeCloseCallback = this._onClose(() => iterator.end());
+        iterator.onEnd = () => {
+            this.listeners.remove(eventName, listener => listener.callback === callback);
+            removeCloseCallback();
+        };
+        if (this.closeEvent != null) {
+            iterator.end();
+        }
+        return iterator;
+    }
+
+    /**
+     * Calls callback with the data of every event whose name matches
+     * eventName, which may contain "*" and "**" wildcard segments. When
+     * several callbacks match an event, they are called in the order they
+     * were added.
+     */
+    on<T = any>(eventName:string, callback:EventCallback<T>) {
+        this._addListener(eventName, callback, false, false);
+    }
+
+    /**
+     * Like on, but removes callback after it is called once.
+     */
+    once<T = any>(eventName:string, callback:EventCallback<T>) {
+        this._addListener(eventName, callback, true, false);
+    }
+
+    /**
+     * Removes callback from the callbacks added for eventName with on or
+     * once. Without a callback, removes every callback added for eventName.
+     */
+    off<T = any>(eventName:string, callback?:EventCallback<T>) {
+        this.listeners.remove(eventName, listener => {
+            return !listener.any && (callback === undefined || listener.callback === callback);
+        });
+    }
+
+    /**
+     * Same as on.
+     */
+    onEvent<T = any>(eventName:string, callback:EventCallback<T>) {
+        this.on(eventName, callback);
+    }
+
+    /**
+     * Calls callback with every event received, whatever its name.
+     */
+    onAny(callback:AnyEventCallback) {
+        this._addListener("**", callback, false, true);
+    }
+
+    /**
+     * Removes callback from the callbacks added with onAny.
+     */
+    offAny(callback:AnyEventCallback) {
+        this.listeners.remove("**", listener => listener.any && listener.callback === callback);
+    }
+
+    /**
+     * Calls callback with every message received that is not valid JSON or
+     * not an event. Without any error callbacks, such messages are logged
+     * to the console.
+     */
+    onError(callback:ErrorCallback) {
+        this.errorCallbacks.push(callback);
+    }
+
+    /**
+     * Removes callback from the callbacks added with onError.
+     */
+    offError(callback:ErrorCallback) {
+        this.errorCallbacks = this.errorCallbacks.filter(errorCallback => errorCallback !== callback);
+    }
+
+    /**
+     * Sends an event named event with data, which is serialized as JSON.
+     */
+    send<T = any>(event:string, data:T) {
+        const text = JSON.stringify({ name: event, data: data });
+        this.webSocket.send(text);
+    }
+
+    /**
+     * Sends an event named event with data, like send, requiring every
+     * client receiving it to acknowledge it. The server retransmits it to
+     * clients that do not. Resolves with the report of its delivery once
+     * every client has acknowledged it or the server gives up, or rejects
+     * if the socket is disconnected or the connection is lost first.
+     */
+    sendAcked<T = any>(event:string, data:T):Promise<DeliveryReport> {
+        return new Promise<DeliveryReport>((resolve, reject) => {
+            if (this.live === null) {
+                reject(new Error("socket is disconnected"));
+                return;
+            }
+            const id = String(++this.ackID);
+            const text = JSON.stringify({ name: event, data: data, id: id });
+            this.pendingAcks[id] = { resolve: resolve, reject: reject };
+            this.webSocket.send(text);
+        });
+    }
+
+    /**
+     * Subscribes to the events matching patterns. Subscriptions are
+     * restored when the socket reconnects.
+     */
+    subscribe(...patterns:string[]) {
+        this.subscriptionEvents.push({ name: "$subscribe", data: patterns });
+        this.send("$subscribe", patterns);
+    }
+
+    unsubscribe(...patterns:string[]) {
+        this.subscriptionEvents.push({ name: "$unsubscribe", data: patterns });
+        this.send("$unsubscribe", patterns);
+    }
+
+    /**
+     * Closes the socket, stopping it from reconnecting.
+     */
+    close(code?:number, reason?:string) {
+        this.closing = true;
+        if (this.reconnectTimer != null) {
+            clearTimeout(this.reconnectTimer);
+            this.reconnectTimer = null;
+            this._handleClose({ code: code !== undefined ? code : 1000, reason: reason || "", wasClean: true });
+            return;
+        }
+        this.webSocket.close(code, reason);
+    }
+
+    readyState():number {
+        return this.webSocket.readyState;
+    }
+
+    private _connect():WebSocket {
+        const self = this;
+        const webSocket = new WebSocket(this.path);
+        webSocket.addEventListener("open", function (this: WebSocket, event: Event) {
+            if (self.live === this) {
+                self._handleOpen(event);
+            }
+        });
+        webSocket.addEventListener("message", function (this: WebSocket, event: MessageEvent) {
+            if (self.live === this) {
+                self._handleMessage(this, event);
+            }
+        });
+        webSocket.addEventListener("close", function (this: WebSocket, event: CloseEvent) {
+            if (self.live === this) {
+                self._handleDisconnect({ code: event.code, reason: event.reason, wasClean: event.wasClean });
+            }
+        });
+        this.live = webSocket;
+        return webSocket;
+    }
+
+    private _handleOpen(event:Event) {
+        this.openEvent = event;
+        this.reconnectAttempts = 0;
+        if (this.connections > 0) {
+            for (const subscription of this.subscriptionEvents) {
+                this.send(subscription.name, subscription.data);
+            }
+        }
+        this.connections++;
+        this._startHeartbeat();
+        for (const callback of this.connectCallbacks.slice()) {
+            callback(this, event);
+        }
+    }
+
+    private _handleDisconnect(close:SocketClose) {
+        this.live = null;
+        this.openEvent = null;
+        this._stopHeartbeat();
+        // Reports of events sent on the lost connection will never arrive.
+        const pendingAcks = this.pendingAcks;
+        this.pendingAcks = {};
+        for (const id in pendingAcks) {
+            pendingAcks[id].reject(closedError(close));
+        }
+        for (const callback of this.disconnectCallbacks.slice()) {
+            callback(this, close);
+        }
+        const reconnect = this.options.reconnect;
+        if (this.closing || reconnect === undefined || (reconnect.attempts !== undefined && this.reconnectAttempts >= reconnect.attempts)) {
+            this._handleClose(close);
+            return;
+        }
+        const delay = reconnect.delay !== undefined ? reconnect.delay : 1000;
+        const maxDelay = reconnect.maxDelay !== undefined ? reconnect.maxDelay : 30000;
+        this.reconnectTimer = setTimeout(() => {
+            this.reconnectTimer = null;
+            this.webSocket = this._connect();
+        }, Math.min(delay * Math.pow(2, this.reconnectAttempts), maxDelay));
+        this.reconnectAttempts++;
+    }
+
+    private _startHeartbeat() {
+        const heartbeat = this.options.heartbeat;
+        if (heartbeat === undefined) {
+            return;
+        }
+        const interval = heartbeat.interval !== undefined ? heartbeat.interval : 25000;
+        const timeout = heartbeat.timeout !== undefined ? heartbeat.timeout : 10000;
+        this.heartbeatTimer = setInterval(() => this._ping(timeout), interval);
+    }
+
+    private _stopHeartbeat() {
+        if (this.heartbeatTimer != null) {
+            clearInterval(this.heartbeatTimer);
+            this.heartbeatTimer = null;
+        }
+        if (this.pongTimer != null) {
+            clearTimeout(this.pongTimer);
+            this.pongTimer = null;
+        }
+        this.pendingPing = null;
+    }
+
+    /**
+     * Sends a heartbeat, treating the connection as dead if it is not
+     * answered within timeout milliseconds.
+     */
+    private _ping(timeout:number) {
+        if (this.pendingPing != null) {
+            return;
+        }
+        this.pendingPing = { id: ++this.pingID, sent: Date.now() };
+        this.send(PING_EVENT, this.pendingPing.id);
+        this.pongTimer = setTimeout(() => {
+            this.pongTimer = null;
+            // A dead connection may take minutes to report closing, so stop
+            // handling its events now.
+            const dead = this.webSocket;
+            this._handleDisconnect({ code: 4000, reason: "heartbeat timeout", wasClean: false });
+            dead.close(4000, "heartbeat timeout");
+        }, timeout);
+    }
+
+    private _handlePong(id:any) {
+        if (this.pendingPing == null || this.pendingPing.id !== id) {
+            return;
+        }
+        const latency = Date.now() - this.pendingPing.sent;
+        this.pendingPing = null;
+        if (this.pongTimer != null) {
+            clearTimeout(this.pongTimer);
+            this.pongTimer = null;
+        }
+        this.lastLatency = latency;
+        for (const callback of this.latencyCallbacks.slice()) {
+            callback(this, latency);
+        }
+    }
+
+    private _handleAck(report:DeliveryReport) {
+        if (report === null || typeof report !== "object" || !Object.prototype.hasOwnProperty.call(this.pendingAcks, report.id)) {
+            return;
+        }
+        const pending = this.pendingAcks[report.id];
+        delete this.pendingAcks[report.id];
+        pending.resolve(report);
+    }
+
+    /**
+     * Records that an event with id was received, returning true if one
+     * already was. Only the most recent SEEN_IDS_LIMIT IDs are remembered.
+     */
+    private _seen(id:string):boolean {
+        if (Object.prototype.hasOwnProperty.call(this.seenIDs, id)) {
+            return true;
+        }
+        this.seenIDs[id] = true;
+        this.seenOrder.push(id);
+        if (this.seenOrder.length > SEEN_IDS_LIMIT) {
+            delete this.seenIDs[this.seenOrder.shift()!];
+        }
+        return false;
+    }
+
+    private _addListener(pattern:string, callback:Function, once:boolean, any:boolean) {
+        this.listeners.add(pattern, { pattern: pattern, callback: callback, once: once, any: any, sequence: this.sequence++ });
+    }
+
+    /**
+     * Calls callback when the socket closes, returning a function that
+     * removes it.
+     */
+    private _onClose(callback:(close:SocketClose) => void):() => void {
+        this.closeCallbacks.push(callback);
+        return () => {
+            this.closeCallbacks = this.closeCallbacks.filter(other => other !== callback);
+        };
+    }
+
+    private _handleClose(close:SocketClose) {
+        this.closeEvent = close;
+        const callbacks = this.closeCallbacks;
+        this.closeCallbacks = [];
+        for (const callback of callbacks) {
+            callback(close);
+        }
+    }
+
+    private _handleMessage(webSocket: WebSocket, event: MessageEvent) {
+        for (const callback of this.messageCallbacks.slice()) {
+            callback(this, event);
+        }
+        try {
+            const reader = new FileReader();
+            reader.addEventListener('loadend', e => {
+                this._messageParsed(webSocket, e.target!.result as string);
+            });
+            reader.readAsText(event.data as Blob);
+        } catch {
+            // data isn't a blob, it must be a string. Pass it directly.
+            this._messageParsed(webSocket, event.data as string);
+        }
+    }
+
+    private _messageParsed(webSocket: WebSocket, jsonString:string) {
+        let obj:SocketEvent<any>;
+        try {
+            obj = JSON.parse(jsonString);
+        } catch (error) {
+            this._error({ text: jsonString, error: error });
+            return;
+        }
+        if (obj === null || typeof obj !== "object" || typeof obj.name !== "string") {
+            this._error({ text: jsonString, error: new Error("message is not an event with a name") });
+            return;
+        }
+        if (obj.name === PONG_EVENT) {
+            this._handlePong(obj.data);
+            return;
+        }
+        if (obj.name === ACK_EVENT) {
+            this._handleAck(obj.data);
+            return;
+        }
+        if (typeof obj.id === "string") {
+            // Acknowledge retransmissions too, in case an earlier
+            // acknowledgement was lost.
+            this.send(ACK_EVENT, obj.id);
+            if (this._seen(obj.id)) {
+                return;
+            }
+        }
+        const listeners = this.listeners.match(obj.name);
+        listeners.sort((a, b) => a.sequence - b.sequence);
+        for (const listener of listeners) {
+            if (listener.once) {
+                this.listeners.remove(listener.pattern, other => other === listener);
+            }
+            listener.callback(this, listener.any ? obj : obj.data);
+        }
+    }
+
+    private _error(error:SocketError) {
+        if (this.errorCallbacks.length == 0) {
+            console.error("Socket received a message that is not an event:", error.text, error.error);
+            return;
+        }
+        for (const callback of this.errorCallbacks.slice()) {
+            callback(this, error);
+        }
+    }
+}
\ No newline at end of file
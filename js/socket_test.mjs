@@ -0,0 +1,237 @@
+// Tests of the behavior of socket.mjs and socket.js against a fake
+// WebSocket. Run with node js/socket_test.mjs; go test runs them when node
+// is installed.
+import { test } from "node:test";
+import assert from "node:assert/strict";
+import fs from "node:fs";
+import vm from "node:vm";
+import { Socket as ModuleSocket } from "./socket.mjs";
+
+// FakeWebSocket records what a Socket sends and lets tests drive its events.
+class FakeWebSocket {
+    constructor(url) {
+        this.url = url;
+        this.readyState = 0;
+        this.handlers = {};
+        this.sent = [];
+        FakeWebSocket.last = this;
+    }
+    addEventListener(type, f) {
+        (this.handlers[type] = this.handlers[type] || []).push(f);
+    }
+    removeEventListener(type, f) {
+        this.handlers[type] = (this.handlers[type] || []).filter(g => g !== f);
+    }
+    send(text) {
+        this.sent.push(text);
+    }
+    close(code, reason) {
+        this.readyState = 3;
+        this.emit("close", { code: code || 1005, reason: reason || "", wasClean: true });
+    }
+    emit(type, event) {
+        for (const f of (this.handlers[type] || []).slice()) {
+            f.call(this, event);
+        }
+    }
+    open() {
+        this.readyState = 1;
+        this.emit("open", {});
+    }
+    receive(event) {
+        this.emit("message", { data: typeof event === "string" ? event : JSON.stringify(event) });
+    }
+}
+globalThis.WebSocket = FakeWebSocket;
+
+// scriptSocket loads the Socket of socket.js, which declares globals
+// rather than exporting, in its own context.
+function scriptSocket() {
+    const context = vm.createContext({ WebSocket: FakeWebSocket, setTimeout, clearTimeout, setInterval, clearInterval });
+    const source = fs.readFileSync(new URL("./socket.js", import.meta.url), "utf8");
+    vm.runInContext(source + "\nthis.Socket = Socket;", context);
+    return context.Socket;
+}
+
+const builds = { "socket.mjs": ModuleSocket, "socket.js": scriptSocket() };
+
+const sleep = ms => new Promise(resolve => setTimeout(resolve, ms));
+
+// connect constructs a Socket and opens its connection.
+function connect(Socket, options) {
+    const socket = new Socket("ws://test", options);
+    const ws = FakeWebSocket.last;
+    ws.open();
+    return { socket, ws };
+}
+
+// json compares values by their JSON, since values created by socket.js
+// belong to another realm.
+function json(actual, expected) {
+    assert.equal(JSON.stringify(actual), JSON.stringify(expected));
+}
+
+for (const [build, Socket] of Object.entries(builds)) {
+    test(build + ": listeners", () => {
+        const { socket, ws } = connect(Socket);
+        const log = [];
+        const exact = (_, data) => log.push("exact" + data);
+        const any = (_, event) => log.push("any:" + event.name);
+        socket.on("chat.message", exact);
+        socket.on("chat.*", (_, data) => log.push("wildcard" + data));
+        socket.once("chat.message", (_, data) => log.push("once" + data));
+        socket.onAny(any);
+        ws.receive({ name: "chat.message", data: 1 });
+        assert.deepEqual(log, ["exact1", "wildcard1", "once1", "any:chat.message"]);
+
+        log.length = 0;
+        socket.off("chat.message", exact);
+        socket.offAny(any);
+        ws.receive({ name: "chat.message", data: 2 });
+        assert.deepEqual(log, ["wildcard2"]);
+
+        const errors = [];
+        socket.onError((_, error) => errors.push(error.text));
+        ws.receive("not json");
+        ws.receive('{"data":1}');
+        assert.deepEqual(errors, ["not json", '{"data":1}']);
+
+        socket.send("x", { y: 1 });
+        assert.equal(ws.sent.at(-1), '{"name":"x","data":{"y":1}}');
+    });
+
+    test(build + ": patterns", () => {
+        const { socket, ws } = connect(Socket);
+        const hits = [];
+        socket.on("**.a.".repeat(20) + "b", () => hits.push("adversarial"));
+        socket.on("x.**.**.y", () => hits.push("collapsed"));
+        socket.on("**", () => hits.push("all"));
+        // Consecutive "**" segments are collapsed and matching is
+        // memoized, so this returns promptly.
+        const start = Date.now();
+        ws.receive({ name: "a.".repeat(40) + "c", data: 1 });
+        assert.ok(Date.now() - start < 1000, "matching took too long");
+        ws.receive({ name: "x.y", data: 1 });
+        socket.off("x.**.y");
+        ws.receive({ name: "x.y", data: 1 });
+        assert.deepEqual(hits, ["all", "collapsed", "all", "all"]);
+    });
+
+    test(build + ": promises", async () => {
+        const socket = new Socket("ws://test");
+        const ws = FakeWebSocket.last;
+        const connected = socket.connected();
+        ws.open();
+        await connected;
+
+        const next = socket.next("chat.*");
+        ws.receive({ name: "chat.a", data: 7 });
+        assert.equal(await next, 7);
+        await assert.rejects(socket.next("never", { timeout: 5 }), /timed out/);
+
+        const seen = [];
+        const loop = (async () => {
+            for await (const event of socket.events("chat.**")) {
+                seen.push(event.name + event.data);
+                if (seen.length == 2) {
+                    break;
+                }
+            }
+        })();
+        ws.receive({ name: "chat.a", data: 1 });
+        ws.receive({ name: "other", data: 0 });
+        ws.receive({ name: "chat.b.c", data: 2 });
+        await loop;
+        assert.deepEqual(seen, ["chat.a1", "chat.b.c2"]);
+
+        const pending = socket.next("never");
+        const closed = socket.closed();
+        ws.close(4000, "bye");
+        const close = await closed;
+        assert.equal(close.code, 4000);
+        assert.equal(close.reason, "bye");
+        await assert.rejects(pending, /socket closed with code 4000: bye/);
+        await assert.rejects(socket.connected(), /4000/);
+    });
+
+    test(build + ": heartbeat and reconnection", async () => {
+        const socket = new Socket("ws://test", { heartbeat: { interval: 10, timeout: 40 }, reconnect: { delay: 5 } });
+        const ws = FakeWebSocket.last;
+        const disconnects = [];
+        const latencies = [];
+        socket.onDisconnect((_, close) => disconnects.push(close.code));
+        socket.onLatency((_, latency) => latencies.push(latency));
+        ws.open();
+        socket.subscribe("a.*");
+
+        await sleep(25);
+        const ping = JSON.parse(ws.sent.find(text => JSON.parse(text).name == "$ping"));
+        ws.receive({ name: "$pong", data: ping.data });
+        assert.equal(latencies.length, 1);
+        assert.equal(typeof socket.latency(), "number");
+
+        // Unanswered pings time the connection out, and the socket
+        // reconnects and restores its subscriptions.
+        await sleep(80);
+        assert.deepEqual(disconnects, [4000]);
+        const reconnected = FakeWebSocket.last;
+        assert.notEqual(reconnected, ws);
+        reconnected.open();
+        assert.equal(reconnected.sent[0], '{"name":"$subscribe","data":["a.*"]}');
+
+        // Events from the dead connection are ignored.
+        let stale = false;
+        socket.on("x", () => stale = true);
+        ws.receive({ name: "x", data: 1 });
+        assert.ok(!stale);
+
+        const closed = socket.closed();
+        socket.close(1000, "done");
+        assert.equal((await closed).reason, "done");
+    });
+
+    test(build + ": acknowledgements", async () => {
+        const { socket, ws } = connect(Socket);
+        const received = [];
+        socket.on("m", (_, data) => received.push(data));
+        // Events with IDs are acknowledged every time and dispatched once.
+        ws.receive({ name: "m", data: 1, id: "abc" });
+        ws.receive({ name: "m", data: 1, id: "abc" });
+        ws.receive({ name: "m", data: 2, id: "constructor" });
+        ws.receive({ name: "m", data: 3 });
+        assert.deepEqual(received, [1, 2, 3]);
+        assert.deepEqual(ws.sent, ['{"name":"$ack","data":"abc"}', '{"name":"$ack","data":"abc"}', '{"name":"$ack","data":"constructor"}']);
+
+        // sendAcked resolves with the matching report, which is not
+        // dispatched to listeners.
+        let any = 0;
+        socket.onAny(() => any++);
+        const first = socket.sendAcked("n", { x: 1 });
+        const second = socket.sendAcked("n", 2);
+        assert.equal(ws.sent[3], '{"name":"n","data":{"x":1},"id":"1"}');
+        assert.equal(ws.sent[4], '{"name":"n","data":2,"id":"2"}');
+        ws.receive({ name: "$ack", data: { id: "2", delivered: [], failed: ["c"] } });
+        ws.receive({ name: "$ack", data: null });
+        json((await second).failed, ["c"]);
+        assert.equal(any, 0);
+
+        // Pending reports are rejected when the connection is lost, and
+        // events cannot be sent acknowledged until it is restored.
+        ws.close(1006);
+        await assert.rejects(first, /socket closed with code 1006/);
+        await assert.rejects(socket.sendAcked("n", 3), /disconnected/);
+        assert.equal(ws.sent.length, 5);
+    });
+
+    test(build + ": deduplication forgets the oldest IDs", () => {
+        const { socket, ws } = connect(Socket);
+        let count = 0;
+        socket.on("e", () => count++);
+        for (let i = 0; i <= 1000; i++) {
+            ws.receive({ name: "e", data: i, id: "i" + i });
+        }
+        ws.receive({ name: "e", data: 0, id: "i0" });
+        ws.receive({ name: "e", data: 0, id: "i1000" });
+        assert.equal(count, 1002);
+    });
+}
@@ -0,0 +1,296 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonSchema is a compiled JSON Schema document. Only the subset of JSON
+// Schema useful for validating event payloads is supported: type, enum,
+// const, properties, required, additionalProperties, items, minItems,
+// maxItems, minLength, maxLength, pattern, minimum and maximum. Unknown
+// keywords are ignored.
+type jsonSchema struct {
+	// types are the allowed JSON types. Empty if any type is allowed.
+	types []string
+	// enum are the allowed values. nil if any value is allowed.
+	enum []any
+	// properties are the schemas of an object's named properties.
+	properties map[string]*jsonSchema
+	// required are the properties an object must have.
+	required []string
+	// additionalProperties is the schema of properties not in properties.
+	// nil if additional properties are unconstrained.
+	additionalProperties *jsonSchema
+	// rejectAll is true if the schema is the boolean schema false.
+	rejectAll bool
+	// items is the schema of an array's elements.
+	items *jsonSchema
+	// Constraints on arrays, strings and numbers. nil if unconstrained.
+	minItems  *int
+	maxItems  *int
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+	minimum   *float64
+	maximum   *float64
+}
+
+// jsonSchemaDocument is the serialized form of jsonSchema.
+type jsonSchemaDocument struct {
+	Type                 json.RawMessage            `json:"type"`
+	Enum                 []json.RawMessage          `json:"enum"`
+	Const                json.RawMessage            `json:"const"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+	Items                json.RawMessage            `json:"items"`
+	MinItems             *int                       `json:"minItems"`
+	MaxItems             *int                       `json:"maxItems"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	Pattern              *string                    `json:"pattern"`
+	Minimum              *float64                   `json:"minimum"`
+	Maximum              *float64                   `json:"maximum"`
+}
+
+// compileJSONSchema parses a JSON Schema document.
+func compileJSONSchema(document []byte) (*jsonSchema, error) {
+	document = bytes.TrimSpace(document)
+	switch string(document) {
+	case "true":
+		return &jsonSchema{}, nil
+	case "false":
+		return &jsonSchema{rejectAll: true}, nil
+	}
+	var doc jsonSchemaDocument
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	schema := &jsonSchema{
+		required:  doc.Required,
+		minItems:  doc.MinItems,
+		maxItems:  doc.MaxItems,
+		minLength: doc.MinLength,
+		maxLength: doc.MaxLength,
+		minimum:   doc.Minimum,
+		maximum:   doc.Maximum,
+	}
+	if len(doc.Type) > 0 {
+		if err := json.Unmarshal(doc.Type, &schema.types); err != nil {
+			var singleType string
+			if err := json.Unmarshal(doc.Type, &singleType); err != nil {
+				return nil, fmt.Errorf("invalid schema: type must be a string or array of strings")
+			}
+			schema.types = []string{singleType}
+		}
+	}
+	for _, value := range doc.Enum {
+		decoded, err := decodeJSONValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema: enum: %w", err)
+		}
+		schema.enum = append(schema.enum, decoded)
+	}
+	if len(doc.Const) > 0 {
+		decoded, err := decodeJSONValue(doc.Const)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema: const: %w", err)
+		}
+		schema.enum = []any{decoded}
+	}
+	if len(doc.Properties) > 0 {
+		schema.properties = make(map[string]*jsonSchema, len(doc.Properties))
+		for name, property := range doc.Properties {
+			compiled, err := compileJSONSchema(property)
+			if err != nil {
+				return nil, fmt.Errorf("property %q: %w", name, err)
+			}
+			schema.properties[name] = compiled
+		}
+	}
+	if len(doc.AdditionalProperties) > 0 {
+		compiled, err := compileJSONSchema(doc.AdditionalProperties)
+		if err != nil {
+			return nil, fmt.Errorf("additionalProperties: %w", err)
+		}
+		schema.additionalProperties = compiled
+	}
+	if len(doc.Items) > 0 {
+		compiled, err := compileJSONSchema(doc.Items)
+		if err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+		schema.items = compiled
+	}
+	if doc.Pattern != nil {
+		pattern, err := regexp.Compile(*doc.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema: pattern: %w", err)
+		}
+		schema.pattern = pattern
+	}
+	return schema, nil
+}
+
+// decodeJSONValue decodes JSON into the generic representation used by
+// jsonSchema, in which all numbers are float64.
+func decodeJSONValue(b []byte) (any, error) {
+	var value any
+	if err := json.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// validate validates data against the schema, returning a description of
+// every violation found. Returns nil if data is valid.
+func (s *jsonSchema) validate(data json.RawMessage) []string {
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = json.RawMessage("null")
+	}
+	value, err := decodeJSONValue(data)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	var violations []string
+	s.validateValue("", value, &violations)
+	return violations
+}
+
+// validateValue appends the violations of value, located at the JSON
+// pointer path, to violations.
+func (s *jsonSchema) validateValue(path string, value any, violations *[]string) {
+	fail := func(format string, args ...any) {
+		location := path
+		if location == "" {
+			location = "/"
+		}
+		*violations = append(*violations, location+": "+fmt.Sprintf(format, args...))
+	}
+	if s.rejectAll {
+		fail("no value is allowed")
+		return
+	}
+	if len(s.types) > 0 && !s.allowsType(value) {
+		fail("expected %s but got %s", strings.Join(s.types, " or "), jsonTypeOf(value))
+		return
+	}
+	if s.enum != nil {
+		found := false
+		for _, allowed := range s.enum {
+			if reflect.DeepEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value is not one of the allowed values")
+		}
+	}
+	switch typed := value.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := typed[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(typed))
+		for name := range typed {
+			names = append(names, name)
+		}
+		// Sort so violations are reported in a stable order.
+		sort.Strings(names)
+		for _, name := range names {
+			propertyPath := path + "/" + escapeJSONPointer(name)
+			if property, ok := s.properties[name]; ok {
+				property.validateValue(propertyPath, typed[name], violations)
+			} else if s.additionalProperties != nil {
+				if s.additionalProperties.rejectAll {
+					fail("unexpected property %q", name)
+				} else {
+					s.additionalProperties.validateValue(propertyPath, typed[name], violations)
+				}
+			}
+		}
+	case []any:
+		if s.minItems != nil && len(typed) < *s.minItems {
+			fail("expected at least %d items but got %d", *s.minItems, len(typed))
+		}
+		if s.maxItems != nil && len(typed) > *s.maxItems {
+			fail("expected at most %d items but got %d", *s.maxItems, len(typed))
+		}
+		if s.items != nil {
+			for i, item := range typed {
+				s.items.validateValue(fmt.Sprintf("%s/%d", path, i), item, violations)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(typed)
+		if s.minLength != nil && length < *s.minLength {
+			fail("expected at least %d characters but got %d", *s.minLength, length)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			fail("expected at most %d characters but got %d", *s.maxLength, length)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(typed) {
+			fail("does not match pattern %q", s.pattern.String())
+		}
+	case float64:
+		if s.minimum != nil && typed < *s.minimum {
+			fail("must be at least %v", *s.minimum)
+		}
+		if s.maximum != nil && typed > *s.maximum {
+			fail("must be at most %v", *s.maximum)
+		}
+	}
+}
+
+// allowsType returns true if value is one of the schema's types.
+func (s *jsonSchema) allowsType(value any) bool {
+	valueType := jsonTypeOf(value)
+	for _, allowed := range s.types {
+		if allowed == valueType {
+			return true
+		}
+		if allowed == "number" && valueType == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeOf returns the JSON Schema type name of a decoded JSON value.
+func jsonTypeOf(value any) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		// Any finite number without a fractional part is an integer,
+		// however large, so this does not convert to an integer type.
+		if !math.IsInf(typed, 0) && typed == math.Trunc(typed) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// escapeJSONPointer escapes a property name for use in a JSON pointer.
+func escapeJSONPointer(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,174 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// chatSchema is a schema exercising most supported keywords.
+const chatSchema = `{
+	"type": "object",
+	"required": ["text", "room"],
+	"properties": {
+		"text": {"type": "string", "minLength": 1, "maxLength": 5},
+		"room": {"type": "string", "pattern": "^room[0-9]+$"},
+		"kind": {"enum": ["say", "shout", null]},
+		"version": {"const": 2},
+		"priority": {"type": "integer", "minimum": 0, "maximum": 10},
+		"score": {"type": "number"},
+		"tags": {"type": "array", "items": {"type": "string"}, "minItems": 1, "maxItems": 2},
+		"author": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {"name": {"type": "string"}},
+			"additionalProperties": false
+		},
+		"extra": {"type": ["string", "null"]}
+	},
+	"additionalProperties": {"type": "boolean"}
+}`
+
+func TestJSONSchemaValidate(t *testing.T) {
+	schema, err := compileJSONSchema([]byte(chatSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		data       string
+		violations []string
+	}{
+		{`{"text":"hi","room":"room1"}`, nil},
+		{`{"text":"hi","room":"room1","kind":null,"version":2,"priority":10,"score":1.5,"tags":["a"],"author":{"name":"cooper"},"extra":null,"flag":true}`, nil},
+		{`{"text":"héllo","room":"room1"}`, nil},
+		{`{"text":"hi","room":"room1","score":3}`, nil},
+		{``, []string{"/: expected object but got null"}},
+		{`[]`, []string{"/: expected object but got array"}},
+		{`{"text":"hi",`, []string{"invalid JSON: unexpected end of JSON input"}},
+		{`{}`, []string{`/: missing required property "text"`, `/: missing required property "room"`}},
+		{`{"text":"","room":"lobby"}`, []string{
+			`/room: does not match pattern "^room[0-9]+$"`,
+			"/text: expected at least 1 characters but got 0",
+		}},
+		{`{"text":"toolong","room":"room1"}`, []string{"/text: expected at most 5 characters but got 7"}},
+		{`{"text":1,"room":"room1"}`, []string{"/text: expected string but got integer"}},
+		{`{"text":"hi","room":"room1","kind":"whisper","version":3}`, []string{
+			"/kind: value is not one of the allowed values",
+			"/version: value is not one of the allowed values",
+		}},
+		{`{"text":"hi","room":"room1","priority":1.5}`, []string{"/priority: expected integer but got number"}},
+		{`{"text":"hi","room":"room1","priority":-1}`, []string{"/priority: must be at least 0"}},
+		// Integers beyond the range of int64 are still integers.
+		{`{"text":"hi","room":"room1","priority":1e20}`, []string{"/priority: must be at most 10"}},
+		{`{"text":-9223372036854775808e2,"room":"room1"}`, []string{"/text: expected string but got integer"}},
+		{`{"text":"hi","room":"room1","priority":11}`, []string{"/priority: must be at most 10"}},
+		{`{"text":"hi","room":"room1","tags":[]}`, []string{"/tags: expected at least 1 items but got 0"}},
+		{`{"text":"hi","room":"room1","tags":["a",2,"c"]}`, []string{
+			"/tags: expected at most 2 items but got 3",
+			"/tags/1: expected string but got integer",
+		}},
+		{`{"text":"hi","room":"room1","author":{"nickname":"c"}}`, []string{
+			`/author: missing required property "name"`,
+			`/author: unexpected property "nickname"`,
+		}},
+		{`{"text":"hi","room":"room1","extra":1}`, []string{"/extra: expected string or null but got integer"}},
+		{`{"text":"hi","room":"room1","a/b~":"x"}`, []string{"/a~1b~0: expected boolean but got string"}},
+	}
+	for _, test := range tests {
+		if violations := schema.validate(json.RawMessage(test.data)); !slices.Equal(violations, test.violations) {
+			t.Errorf("validate(%s):\ngot      %q\nexpected %q", test.data, violations, test.violations)
+		}
+	}
+}
+
+func TestJSONSchemaBooleanSchemas(t *testing.T) {
+	for _, test := range []struct {
+		schema string
+		valid  bool
+	}{{"true", true}, {" false ", false}} {
+		schema, err := compileJSONSchema([]byte(test.schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if valid := schema.validate(json.RawMessage(`{"any":"thing"}`)) == nil; valid != test.valid {
+			t.Errorf("schema %s: expected valid %v", test.schema, test.valid)
+		}
+	}
+}
+
+func TestCompileJSONSchemaErrors(t *testing.T) {
+	tests := []struct {
+		schema string
+		err    string
+	}{
+		{`[]`, "invalid schema"},
+		{`{"type": 1}`, "type must be a string or array of strings"},
+		{`{"pattern": "("}`, "pattern"},
+		{`{"properties": {"name": {"pattern": "["}}}`, `property "name"`},
+		{`{"items": {"type": 2}}`, "items"},
+		{`{"additionalProperties": 3}`, "additionalProperties"},
+	}
+	for _, test := range tests {
+		if _, err := compileJSONSchema([]byte(test.schema)); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("compileJSONSchema(%s): expected an error containing %q, got %v", test.schema, test.err, err)
+		}
+	}
+}
+
+func TestEventRegistryValidate(t *testing.T) {
+	registry := NewEventRegistry()
+	if err := registry.AllowSchema("chat", []byte(chatSchema)); err != nil {
+		t.Fatal(err)
+	}
+	registry.Allow("free", nil)
+	registry.Allow("custom", func(json.RawMessage) error { return errors.New("no thanks") })
+
+	if err := registry.Validate(Event{Name: "chat", Data: json.RawMessage(`{"text":"hi","room":"room1"}`)}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := registry.Validate(Event{Name: "free", Data: json.RawMessage(`anything`)}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	registry.Disallow("free")
+
+	tests := []struct {
+		event Event
+		data  ErrorEventData
+	}{
+		{Event{Name: "free"}, ErrorEventData{
+			Code:    ErrorCodeEventNotAllowed,
+			Message: `event "free" is not allowed`,
+			Event:   "free",
+		}},
+		{Event{Name: "chat", Data: json.RawMessage(`{"text":"hi"}`)}, ErrorEventData{
+			Code:    ErrorCodeInvalidPayload,
+			Message: `event "chat" has an invalid payload: /: missing required property "room"`,
+			Event:   "chat",
+			Details: []string{`/: missing required property "room"`},
+		}},
+		{Event{Name: "custom"}, ErrorEventData{
+			Code:    ErrorCodeInvalidPayload,
+			Message: `event "custom" has an invalid payload: no thanks`,
+			Event:   "custom",
+			Details: []string{"no thanks"},
+		}},
+	}
+	for _, test := range tests {
+		err := registry.Validate(test.event)
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: expected a *ValidationError, got %v", test.event.Name, err)
+			continue
+		}
+		event := validationErr.errorEvent()
+		var data ErrorEventData
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+		if event.Name != ErrorEventName || data.Code != test.data.Code || data.Message != test.data.Message || data.Event != test.data.Event || !slices.Equal(data.Details, test.data.Details) {
+			t.Errorf("%s: unexpected error event %s %+v, expected %+v", test.event.Name, event.Name, data, test.data)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// logCapture is an io.Writer for a slog.JSONHandler, decoding the records
+// written to it.
+type logCapture struct {
+	mu      sync.Mutex
+	records []map[string]any
+}
+
+func (c *logCapture) Write(b []byte) (int, error) {
+	var record map[string]any
+	if err := json.Unmarshal(b, &record); err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = append(c.records, record)
+	return len(b), nil
+}
+
+// logger returns a Logger writing every record to c.
+func (c *logCapture) logger() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(c, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+// find returns the first record with message msg, or nil.
+func (c *logCapture) find(msg string) map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, record := range c.records {
+		if record[slog.MessageKey] == msg {
+			return record
+		}
+	}
+	return nil
+}
+
+// expect fails the test unless a record with message msg was logged at
+// level with attrs.
+func (c *logCapture) expect(t *testing.T, level slog.Level, msg string, attrs map[string]any) {
+	t.Helper()
+	record := c.find(msg)
+	if record == nil {
+		t.Errorf("expected %q to be logged", msg)
+		return
+	}
+	if record[slog.LevelKey] != level.String() {
+		t.Errorf("expected %q to be logged at %s, got %v", msg, level, record[slog.LevelKey])
+	}
+	for key, value := range attrs {
+		if record[key] != value {
+			t.Errorf("expected %q to have %s %v, got %v", msg, key, value, record[key])
+		}
+	}
+}
+
+// TestHubLogging checks the levels and attributes of the Hub's log
+// records, and that they go to Hub.Logger rather than the default Logger.
+func TestHubLogging(t *testing.T) {
+	var defaultLog bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&defaultLog, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	capture := &logCapture{}
+	hub := NewHub()
+	hub.Key = "chat"
+	hub.Logger = capture.logger()
+	go hub.Run()
+	defer hub.Close()
+
+	hub.Register(newReplayClient("a"), ClientRegistrationOptions{})
+	full := newPropertyClient(0, false)
+	hub.Register(full, ClientRegistrationOptions{})
+	var fullID string
+	hub.query(func() { fullID = hub.clients[full].id })
+	hub.BroadcastAll("message", nil)
+	if err := hub.Disconnect("a", "bye"); err != nil {
+		t.Fatal(err)
+	}
+	hub.query(func() {})
+
+	// JSON numbers decode as float64.
+	capture.expect(t, slog.LevelDebug, "registered client", map[string]any{"hub": "chat", "client": "a", "clients": 1.0})
+	capture.expect(t, slog.LevelWarn, "dropping client that is not keeping up with events", map[string]any{"hub": "chat", "client": fullID})
+	capture.expect(t, slog.LevelInfo, "disconnecting client", map[string]any{"hub": "chat", "client": "a", "reason": "bye"})
+	if defaultLog.Len() != 0 {
+		t.Errorf("expected nothing to be logged to the default Logger, got %s", defaultLog.String())
+	}
+}
+
+// TestWebsocketClientLogger checks that WebsocketOptions.Logger overrides
+// the Hub's Logger for a WebsocketClient, with the client's attributes.
+func TestWebsocketClientLogger(t *testing.T) {
+	hubLog, clientLog := &logCapture{}, &logCapture{}
+	hub := NewHub()
+	hub.Key = "chat"
+	hub.Logger = hubLog.logger()
+	go hub.Run()
+	defer hub.Close()
+	clients := make(chan *WebsocketClient, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		client, err := ServeWebsocketWithOptions(hub, w, req, WebsocketOptions{Logger: clientLog.logger()})
+		if err == nil {
+			clients <- client
+		}
+	}))
+	defer server.Close()
+	conn, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := <-clients
+
+	if err := conn.WriteMessage(gorilla.TextMessage, []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for clientLog.find("skipping malformed event") == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	clientLog.expect(t, slog.LevelWarn, "skipping malformed event", map[string]any{
+		"hub":         "chat",
+		"client":      client.id,
+		"remote_addr": conn.LocalAddr().String(),
+	})
+	if hubLog.find("skipping malformed event") != nil {
+		t.Error("expected the client's record to go to its own Logger only")
+	}
+}
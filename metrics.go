@@ -0,0 +1,297 @@
+package websocket
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics receives measurements from Hubs and WebsocketClients. Every
+// method is labeled with the Key of the Hub the measurement belongs to.
+// Implementations must be safe for concurrent use and must not block, since
+// they are called from the Hub's Run goroutine.
+type Metrics interface {
+	// ClientRegistered is called when a client is registered with a Hub.
+	ClientRegistered(hub string)
+	// ClientUnregistered is called when a client is removed from a Hub for
+	// any reason, including being dropped.
+	ClientUnregistered(hub string)
+	// ClientDropped is called when a Hub closes a client because the
+	// client's buffer was full.
+	ClientDropped(hub string)
+	// EventReceived is called when a WebsocketClient reads an event of the
+	// given size in bytes from its peer.
+	EventReceived(hub string, bytes int)
+	// EventThrottled is called when a WebsocketClient throttles an event
+	// because its peer exceeded its rate limit.
+	EventThrottled(hub string)
+	// NotificationDropped is called when a Hub drops a notification for
+	// its observers because too many are queued.
+	NotificationDropped(hub string)
+	// EventBroadcast is called when a Hub broadcasts an event.
+	EventBroadcast(hub string)
+	// EventDelivered is called when a Hub queues an event for a client.
+	EventDelivered(hub string)
+	// EventSent is called when a WebsocketClient writes an event of the
+	// given size in bytes to its peer.
+	EventSent(hub string, bytes int)
+	// PingRTT is called when a WebsocketClient receives the response to a
+	// ping, with the round trip time of the ping.
+	PingRTT(hub string, rtt time.Duration)
+}
+
+// noopMetrics is the Metrics used when a Hub has none.
+type noopMetrics struct{}
+
+func (noopMetrics) ClientRegistered(string)       {}
+func (noopMetrics) ClientUnregistered(string)     {}
+func (noopMetrics) ClientDropped(string)          {}
+func (noopMetrics) EventReceived(string, int)     {}
+func (noopMetrics) EventThrottled(string)         {}
+func (noopMetrics) NotificationDropped(string)    {}
+func (noopMetrics) EventBroadcast(string)         {}
+func (noopMetrics) EventDelivered(string)         {}
+func (noopMetrics) EventSent(string, int)         {}
+func (noopMetrics) PingRTT(string, time.Duration) {}
+
+// metrics returns the Hub's Metrics, or a Metrics discarding all
+// measurements if it has none.
+func (h *Hub) metrics() Metrics {
+	if h.Metrics == nil {
+		return noopMetrics{}
+	}
+	return h.Metrics
+}
+
+// pingRTTBuckets are the upper bounds, in seconds, of the buckets of the
+// ping round trip time histogram.
+var pingRTTBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// HubMetrics are the measurements of a single Hub recorded by MemoryMetrics.
+type HubMetrics struct {
+	// Clients is the number of clients currently registered.
+	Clients int64
+	// ClientsRegistered is the total number of clients registered.
+	ClientsRegistered uint64
+	// ClientsDropped is the total number of clients dropped for being too
+	// slow.
+	ClientsDropped uint64
+	// EventsReceived is the total number of events read from peers.
+	EventsReceived uint64
+	// BytesReceived is the total size of the events read from peers.
+	BytesReceived uint64
+	// EventsThrottled is the total number of events throttled.
+	EventsThrottled uint64
+	// NotificationsDropped is the total number of observer notifications
+	// dropped.
+	NotificationsDropped uint64
+	// EventsBroadcast is the total number of events broadcast.
+	EventsBroadcast uint64
+	// EventsDelivered is the total number of events queued for clients.
+	EventsDelivered uint64
+	// EventsSent is the total number of events written to peers.
+	EventsSent uint64
+	// BytesSent is the total size of the events written to peers.
+	BytesSent uint64
+	// PingRTTBuckets are the cumulative counts of ping round trip times
+	// less than or equal to each of the bounds in pingRTTBuckets.
+	PingRTTBuckets []uint64
+	// PingRTTCount is the number of ping round trip times observed.
+	PingRTTCount uint64
+	// PingRTTSum is the sum of the ping round trip times observed.
+	PingRTTSum time.Duration
+}
+
+// MemoryMetrics is a Metrics recording measurements in memory. It is also
+// an http.Handler serving the measurements in the Prometheus text
+// exposition format, labeled by hub.
+type MemoryMetrics struct {
+	// mu guards hubs.
+	mu sync.Mutex
+	// hubs are the measurements of each hub, keyed by Hub.Key.
+	hubs map[string]*HubMetrics
+}
+
+// NewMemoryMetrics constructs a MemoryMetrics with no measurements.
+func NewMemoryMetrics() *MemoryMetrics {
+	return &MemoryMetrics{hubs: make(map[string]*HubMetrics)}
+}
+
+// update calls f with the measurements of hub while holding the lock.
+func (m *MemoryMetrics) update(hub string, f func(*HubMetrics)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	metrics, ok := m.hubs[hub]
+	if !ok {
+		metrics = &HubMetrics{PingRTTBuckets: make([]uint64, len(pingRTTBuckets))}
+		m.hubs[hub] = metrics
+	}
+	f(metrics)
+}
+
+func (m *MemoryMetrics) ClientRegistered(hub string) {
+	m.update(hub, func(metrics *HubMetrics) {
+		metrics.Clients++
+		metrics.ClientsRegistered++
+	})
+}
+
+func (m *MemoryMetrics) ClientUnregistered(hub string) {
+	m.update(hub, func(metrics *HubMetrics) { metrics.Clients-- })
+}
+
+func (m *MemoryMetrics) ClientDropped(hub string) {
+	m.update(hub, func(metrics *HubMetrics) { metrics.ClientsDropped++ })
+}
+
+func (m *MemoryMetrics) EventReceived(hub string, bytes int) {
+	m.update(hub, func(metrics *HubMetrics) {
+		metrics.EventsReceived++
+		metrics.BytesReceived += uint64(bytes)
+	})
+}
+
+func (m *MemoryMetrics) EventThrottled(hub string) {
+	m.update(hub, func(metrics *HubMetrics) { metrics.EventsThrottled++ })
+}
+
+func (m *MemoryMetrics) NotificationDropped(hub string) {
+	m.update(hub, func(metrics *HubMetrics) { metrics.NotificationsDropped++ })
+}
+
+func (m *MemoryMetrics) EventBroadcast(hub string) {
+	m.update(hub, func(metrics *HubMetrics) { metrics.EventsBroadcast++ })
+}
+
+func (m *MemoryMetrics) EventDelivered(hub string) {
+	m.update(hub, func(metrics *HubMetrics) { metrics.EventsDelivered++ })
+}
+
+func (m *MemoryMetrics) EventSent(hub string, bytes int) {
+	m.update(hub, func(metrics *HubMetrics) {
+		metrics.EventsSent++
+		metrics.BytesSent += uint64(bytes)
+	})
+}
+
+func (m *MemoryMetrics) PingRTT(hub string, rtt time.Duration) {
+	m.update(hub, func(metrics *HubMetrics) {
+		for i, bound := range pingRTTBuckets {
+			if rtt.Seconds() <= bound {
+				metrics.PingRTTBuckets[i]++
+			}
+		}
+		metrics.PingRTTCount++
+		metrics.PingRTTSum += rtt
+	})
+}
+
+// Hubs returns a copy of the measurements of every hub, keyed by Hub.Key.
+func (m *MemoryMetrics) Hubs() map[string]HubMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hubs := make(map[string]HubMetrics, len(m.hubs))
+	for key, metrics := range m.hubs {
+		copied := *metrics
+		copied.PingRTTBuckets = append([]uint64(nil), metrics.PingRTTBuckets...)
+		hubs[key] = copied
+	}
+	return hubs
+}
+
+// ServeHTTP writes the measurements in the Prometheus text exposition
+// format.
+func (m *MemoryMetrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
+
+// prometheusMetric describes a single metric family derived from HubMetrics.
+type prometheusMetric struct {
+	name       string
+	help       string
+	metricType string
+	value      func(HubMetrics) float64
+}
+
+var prometheusMetrics = []prometheusMetric{
+	{"websocket_clients", "Number of clients registered with the hub.", "gauge",
+		func(m HubMetrics) float64 { return float64(m.Clients) }},
+	{"websocket_clients_registered_total", "Total number of clients registered with the hub.", "counter",
+		func(m HubMetrics) float64 { return float64(m.ClientsRegistered) }},
+	{"websocket_clients_dropped_total", "Total number of clients dropped for not keeping up with events.", "counter",
+		func(m HubMetrics) float64 { return float64(m.ClientsDropped) }},
+	{"websocket_events_received_total", "Total number of events read from websocket peers.", "counter",
+		func(m HubMetrics) float64 { return float64(m.EventsReceived) }},
+	{"websocket_received_bytes_total", "Total size in bytes of events read from websocket peers.", "counter",
+		func(m HubMetrics) float64 { return float64(m.BytesReceived) }},
+	{"websocket_events_throttled_total", "Total number of events throttled by rate limits.", "counter",
+		func(m HubMetrics) float64 { return float64(m.EventsThrottled) }},
+	{"websocket_events_broadcast_total", "Total number of events broadcast by the hub.", "counter",
+		func(m HubMetrics) float64 { return float64(m.EventsBroadcast) }},
+	{"websocket_events_delivered_total", "Total number of events queued for clients.", "counter",
+		func(m HubMetrics) float64 { return float64(m.EventsDelivered) }},
+	{"websocket_events_sent_total", "Total number of events written to websocket peers.", "counter",
+		func(m HubMetrics) float64 { return float64(m.EventsSent) }},
+	{"websocket_sent_bytes_total", "Total size in bytes of events written to websocket peers.", "counter",
+		func(m HubMetrics) float64 { return float64(m.BytesSent) }},
+	{"websocket_notifications_dropped_total", "Total number of observer notifications dropped because too many were queued.", "counter",
+		func(m HubMetrics) float64 { return float64(m.NotificationsDropped) }},
+}
+
+// WritePrometheus writes the measurements to w in the Prometheus text
+// exposition format.
+func (m *MemoryMetrics) WritePrometheus(w io.Writer) error {
+	hubs := m.Hubs()
+	keys := make([]string, 0, len(hubs))
+	for key := range hubs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, metric := range prometheusMetrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.metricType)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "%s{hub=\"%s\"} %s\n", metric.name, escapeLabelValue(key), formatFloat(metric.value(hubs[key])))
+		}
+	}
+	const rttName = "websocket_ping_rtt_seconds"
+	fmt.Fprintf(&b, "# HELP %s Round trip time of websocket pings.\n# TYPE %s histogram\n", rttName, rttName)
+	for _, key := range keys {
+		label := escapeLabelValue(key)
+		metrics := hubs[key]
+		for i, bound := range pingRTTBuckets {
+			fmt.Fprintf(&b, "%s_bucket{hub=\"%s\",le=\"%s\"} %d\n", rttName, label, formatFloat(bound), metrics.PingRTTBuckets[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket{hub=\"%s\",le=\"+Inf\"} %d\n", rttName, label, metrics.PingRTTCount)
+		fmt.Fprintf(&b, "%s_sum{hub=\"%s\"} %s\n", rttName, label, formatFloat(metrics.PingRTTSum.Seconds()))
+		fmt.Fprintf(&b, "%s_count{hub=\"%s\"} %d\n", rttName, label, metrics.PingRTTCount)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeLabelValue escapes a Prometheus label value.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatFloat formats a sample value as Prometheus expects.
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,115 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordSampleMetrics records a measurement of every kind.
+func recordSampleMetrics(metrics *MemoryMetrics) {
+	metrics.ClientRegistered("chat")
+	metrics.ClientRegistered("chat")
+	metrics.ClientUnregistered("chat")
+	metrics.ClientDropped("chat")
+	metrics.EventReceived("chat", 10)
+	metrics.EventReceived("chat", 5)
+	metrics.EventThrottled("chat")
+	metrics.NotificationDropped("chat")
+	metrics.EventBroadcast("chat")
+	metrics.EventDelivered("chat")
+	metrics.EventDelivered("chat")
+	metrics.EventSent("chat", 20)
+	metrics.PingRTT("chat", 3*time.Millisecond)
+	metrics.PingRTT("chat", 2*time.Second)
+	// A key that must be escaped as a label value.
+	metrics.EventBroadcast("a \"b\"\\\n")
+}
+
+func TestMemoryMetrics(t *testing.T) {
+	metrics := NewMemoryMetrics()
+	recordSampleMetrics(metrics)
+	chat := metrics.Hubs()["chat"]
+	expected := HubMetrics{
+		Clients:              1,
+		ClientsRegistered:    2,
+		ClientsDropped:       1,
+		EventsReceived:       2,
+		BytesReceived:        15,
+		EventsThrottled:      1,
+		NotificationsDropped: 1,
+		EventsBroadcast:      1,
+		EventsDelivered:      2,
+		EventsSent:           1,
+		BytesSent:            20,
+		PingRTTCount:         2,
+		PingRTTSum:           2003 * time.Millisecond,
+	}
+	buckets := chat.PingRTTBuckets
+	chat.PingRTTBuckets = nil
+	if !reflect.DeepEqual(chat, expected) {
+		t.Errorf("expected %+v, got %+v", expected, chat)
+	}
+	// 3ms falls in every bucket, and 2s only in those of 2.5s and above.
+	for i, bound := range pingRTTBuckets {
+		count := uint64(1)
+		if bound >= 2.5 {
+			count = 2
+		}
+		if buckets[i] != count {
+			t.Errorf("expected %d round trips of at most %vs, got %d", count, bound, buckets[i])
+		}
+	}
+}
+
+// TestWritePrometheus compares the exposition of sample measurements with
+// testdata/metrics.prom.
+func TestWritePrometheus(t *testing.T) {
+	metrics := NewMemoryMetrics()
+	recordSampleMetrics(metrics)
+	expected, err := os.ReadFile("testdata/metrics.prom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("unexpected Content-Type %q", contentType)
+	}
+	if body := recorder.Body.String(); body != string(expected) {
+		t.Errorf("unexpected exposition:\n%s\nexpected\n%s", body, expected)
+	}
+}
+
+// TestHubMetrics checks the measurements a Hub records itself.
+func TestHubMetrics(t *testing.T) {
+	metrics := NewMemoryMetrics()
+	hub := NewHub()
+	hub.Key = "chat"
+	hub.Metrics = metrics
+	go hub.Run()
+	defer hub.Close()
+
+	a, b, full := newPropertyClient(1, false), newPropertyClient(1, false), newPropertyClient(0, false)
+	hub.Register(a, ClientRegistrationOptions{})
+	hub.Register(b, ClientRegistrationOptions{})
+	hub.Register(full, ClientRegistrationOptions{})
+	hub.Broadcast(a, "chat", nil)
+	hub.Unregister(b)
+	hub.query(func() {})
+
+	expected := HubMetrics{
+		Clients:           1,
+		ClientsRegistered: 3,
+		ClientsDropped:    1,
+		EventsBroadcast:   1,
+		EventsDelivered:   1,
+		PingRTTBuckets:    make([]uint64, len(pingRTTBuckets)),
+	}
+	if chat := metrics.Hubs()["chat"]; !reflect.DeepEqual(chat, expected) {
+		t.Errorf("expected %+v, got %+v", expected, chat)
+	}
+}
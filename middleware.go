@@ -0,0 +1,63 @@
+package websocket
+
+// Handler handles a ClientEvent travelling through a Hub.
+type Handler func(event ClientEvent)
+
+// Interceptor wraps a Handler to form a middleware chain. An Interceptor
+// may inspect event before passing it on, transform or enrich it by passing
+// a modified copy to next, drop it by not calling next, or redirect it by
+// handing it to something else (such as another Hub's Broadcast) instead.
+type Interceptor func(next Handler) Handler
+
+// chain composes interceptors around handler. The first interceptor is the
+// outermost, so it sees each event first.
+func chain(handler Handler, interceptors []Interceptor) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		handler = interceptors[i](handler)
+	}
+	return handler
+}
+
+// UseInbound appends interceptors to the Hub's inbound chain. The inbound
+// chain sees every event passed to Broadcast or BroadcastAll, on the
+// caller's goroutine, before it is queued for the Hub. Interceptors may
+// block without stalling the Hub.
+func (h *Hub) UseInbound(interceptors ...Interceptor) {
+	h.middlewareLock.Lock()
+	defer h.middlewareLock.Unlock()
+	h.inboundInterceptors = append(h.inboundInterceptors, interceptors...)
+	h.inbound = chain(h.enqueueBroadcast, h.inboundInterceptors)
+}
+
+// UseOutbound appends interceptors to the Hub's outbound chain. The outbound
+// chain sees every event once, immediately before the Hub delivers it to
+// its clients. It runs on the Hub's Run goroutine, so interceptors must not
+// block or call the Hub's blocking methods (such as Broadcast or Register);
+// spawn a goroutine to do so. next must be called, if at all, before the
+// interceptor returns.
+func (h *Hub) UseOutbound(interceptors ...Interceptor) {
+	h.middlewareLock.Lock()
+	defer h.middlewareLock.Unlock()
+	h.outboundInterceptors = append(h.outboundInterceptors, interceptors...)
+	h.outbound = chain(h.deliver, h.outboundInterceptors)
+}
+
+// inboundHandler returns the head of the inbound chain.
+func (h *Hub) inboundHandler() Handler {
+	h.middlewareLock.RLock()
+	defer h.middlewareLock.RUnlock()
+	if h.inbound == nil {
+		return h.enqueueBroadcast
+	}
+	return h.inbound
+}
+
+// outboundHandler returns the head of the outbound chain.
+func (h *Hub) outboundHandler() Handler {
+	h.middlewareLock.RLock()
+	defer h.middlewareLock.RUnlock()
+	if h.outbound == nil {
+		return h.deliver
+	}
+	return h.outbound
+}
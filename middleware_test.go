@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// middlewareLog records which interceptors saw which events.
+type middlewareLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+// interceptor returns an Interceptor logging name and the name of every
+// event it sees before passing it on.
+func (l *middlewareLog) interceptor(name string) Interceptor {
+	return func(next Handler) Handler {
+		return func(event ClientEvent) {
+			l.mu.Lock()
+			l.entries = append(l.entries, name+" "+event.Event.Name)
+			l.mu.Unlock()
+			next(event)
+		}
+	}
+}
+
+func (l *middlewareLog) all() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.entries...)
+}
+
+// dropping returns an Interceptor dropping events named name.
+func dropping(name string) Interceptor {
+	return func(next Handler) Handler {
+		return func(event ClientEvent) {
+			if event.Event.Name != name {
+				next(event)
+			}
+		}
+	}
+}
+
+// expectNoClientEvent fails the test if client has an event queued.
+func expectNoClientEvent(t *testing.T, client *propertyClient) {
+	t.Helper()
+	select {
+	case clientEvent := <-client.send:
+		t.Errorf("unexpected event %q", clientEvent.Event.Name)
+	default:
+	}
+}
+
+// TestMiddlewareOrder checks that interceptors run in the order they were
+// added, inbound before outbound, and that the outbound chain runs once
+// per event rather than once per recipient.
+func TestMiddlewareOrder(t *testing.T) {
+	hub := NewHub()
+	log := &middlewareLog{}
+	hub.UseInbound(log.interceptor("inbound 1"))
+	hub.UseInbound(log.interceptor("inbound 2"))
+	hub.UseOutbound(log.interceptor("outbound 1"), log.interceptor("outbound 2"))
+	go hub.Run()
+	defer hub.Close()
+
+	recipients := []*propertyClient{newPropertyClient(1, false), newPropertyClient(1, false), newPropertyClient(1, false)}
+	for _, recipient := range recipients {
+		hub.Register(recipient, ClientRegistrationOptions{})
+	}
+	hub.BroadcastAll("chat", []byte(`"hi"`))
+	for _, recipient := range recipients {
+		expectClientEvent(t, recipient)
+	}
+
+	expected := []string{"inbound 1 chat", "inbound 2 chat", "outbound 1 chat", "outbound 2 chat"}
+	if entries := log.all(); !slices.Equal(entries, expected) {
+		t.Errorf("expected interceptors %q, got %q", expected, entries)
+	}
+}
+
+func TestMiddlewareDrop(t *testing.T) {
+	hub := NewHub()
+	log := &middlewareLog{}
+	hub.UseInbound(dropping("spam"))
+	hub.UseOutbound(log.interceptor("outbound"), dropping("secret"))
+	go hub.Run()
+	defer hub.Close()
+
+	recipient := newPropertyClient(4, false)
+	hub.Register(recipient, ClientRegistrationOptions{})
+	for _, name := range []string{"spam", "secret", "chat"} {
+		hub.BroadcastAll(name, nil)
+	}
+	if name := expectClientEvent(t, recipient).Event.Name; name != "chat" {
+		t.Errorf("expected only chat to be delivered, got %q", name)
+	}
+	expectNoClientEvent(t, recipient)
+
+	// Events dropped inbound never reach the outbound chain.
+	expected := []string{"outbound secret", "outbound chat"}
+	if entries := log.all(); !slices.Equal(entries, expected) {
+		t.Errorf("expected outbound to see %q, got %q", expected, entries)
+	}
+}
+
+func TestMiddlewareRewrite(t *testing.T) {
+	hub := NewHub()
+	hub.UseInbound(func(next Handler) Handler {
+		return func(event ClientEvent) {
+			event.Event.Name = "chat.message"
+			next(event)
+		}
+	})
+	hub.UseOutbound(func(next Handler) Handler {
+		return func(event ClientEvent) {
+			event.Event.Data = []byte(`"redacted"`)
+			next(event)
+		}
+	})
+	go hub.Run()
+	defer hub.Close()
+
+	sender, recipient := newPropertyClient(1, false), newPropertyClient(1, false)
+	hub.Register(sender, ClientRegistrationOptions{})
+	hub.Register(recipient, ClientRegistrationOptions{})
+	hub.Broadcast(sender, "chat", []byte(`"hi"`))
+
+	clientEvent := expectClientEvent(t, recipient)
+	if clientEvent.Event.Name != "chat.message" || string(clientEvent.Event.Data) != `"redacted"` {
+		t.Errorf("expected the rewritten event, got %q %s", clientEvent.Event.Name, clientEvent.Event.Data)
+	}
+	if clientEvent.Client != sender {
+		t.Error("expected the rewritten event to keep its sender")
+	}
+	select {
+	case clientEvent := <-sender.send:
+		t.Errorf("sender received its own event %q", clientEvent.Event.Name)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
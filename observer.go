@@ -0,0 +1,288 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+// UnregisterReason describes why a client was removed from a Hub.
+type UnregisterReason int
+
+const (
+	// UnregisterRequested means Unregister was called, usually because the
+	// client disconnected.
+	UnregisterRequested UnregisterReason = iota
+	// UnregisterDropped means the client was dropped because its buffer was
+	// full.
+	UnregisterDropped
+	// UnregisterHubClosed means the Hub closed.
+	UnregisterHubClosed
+	// UnregisterDisconnected means Disconnect was called.
+	UnregisterDisconnected
+)
+
+func (r UnregisterReason) String() string {
+	switch r {
+	case UnregisterRequested:
+		return "requested"
+	case UnregisterDropped:
+		return "dropped"
+	case UnregisterHubClosed:
+		return "hub closed"
+	case UnregisterDisconnected:
+		return "disconnected"
+	}
+	return "unknown"
+}
+
+// HubObserver is notified of the lifecycle events of a Hub. Clients are
+// identified by the Client and the ID the Hub knows them by (see
+// Identifier).
+//
+// A Hub never calls its observers from its Run goroutine. Notifications
+// are queued and delivered, in order, on a separate goroutine, so
+// observers may call any of the Hub's methods without deadlocking it.
+// Because notifications are queued, an observer that is slow to return
+// delays later notifications without stalling the Hub. At most
+// Hub.ObserverBuffer notifications are queued: while the queue is full,
+// further notifications are dropped and counted by
+// Metrics.NotificationDropped, so an observer that falls behind misses
+// notifications rather than growing the Hub's memory without limit.
+// OnClose is never dropped, and neither is any notification of a
+// LosslessObserver.
+type HubObserver interface {
+	// OnRegister is called when a client is registered.
+	OnRegister(client Client, id string)
+	// OnUnregister is called when a client is removed, for any reason.
+	OnUnregister(client Client, id string, reason UnregisterReason)
+	// OnBroadcast is called when an event is broadcast. senderID is the ID
+	// of the client that sent the event, or empty if it was sent with
+	// BroadcastAll.
+	OnBroadcast(event ClientEvent, senderID string)
+	// OnDrop is called when a client is dropped because its buffer was
+	// full, before OnUnregister.
+	OnDrop(client Client, id string)
+	// OnIdleTimeout is called when the Hub closes because no events were
+	// sent for its CloseTimeout, before OnClose.
+	OnIdleTimeout()
+	// OnClose is called once the Hub has closed and unregistered all its
+	// clients. It is the last notification an observer receives.
+	OnClose()
+}
+
+// LosslessObserver is a HubObserver that must receive every notification,
+// such as Recorder, whose recording is incomplete without them. A Hub
+// never drops the notifications of an observer whose Lossless returns
+// true, even while its queue is full, so such an observer must keep up
+// with the Hub or grow its memory without limit.
+type LosslessObserver interface {
+	HubObserver
+	// Lossless returns true if the observer must receive every
+	// notification. It is called once, by Observe.
+	Lossless() bool
+}
+
+// BaseHubObserver implements HubObserver by doing nothing. Embed it to
+// implement only some of HubObserver's methods.
+type BaseHubObserver struct{}
+
+func (BaseHubObserver) OnRegister(Client, string)                     {}
+func (BaseHubObserver) OnUnregister(Client, string, UnregisterReason) {}
+func (BaseHubObserver) OnBroadcast(ClientEvent, string)               {}
+func (BaseHubObserver) OnDrop(Client, string)                         {}
+func (BaseHubObserver) OnIdleTimeout()                                {}
+func (BaseHubObserver) OnClose()                                      {}
+
+// observerBuffer returns how many notifications are queued for the Hub's
+// observers before further notifications are dropped.
+func (h *Hub) observerBuffer() int {
+	if h.ObserverBuffer <= 0 {
+		return DefaultObserverBuffer
+	}
+	return h.ObserverBuffer
+}
+
+// Observe adds observer to the Hub. Observers added after the Hub closes
+// are never notified.
+func (h *Hub) Observe(observer HubObserver) {
+	h.observe(observer)
+}
+
+// observe implements Observe. Returns false, without adding observer, if
+// the Hub has closed, so observer would never be notified, not even of
+// OnClose.
+func (h *Hub) observe(observer HubObserver) bool {
+	lossless, ok := observer.(LosslessObserver)
+	entry := observerEntry{observer: observer, lossless: ok && lossless.Lossless()}
+	h.notifications.mu.Lock()
+	defer h.notifications.mu.Unlock()
+	if h.notifications.closed {
+		return false
+	}
+	h.notifications.observers = append(h.notifications.observers, entry)
+	return true
+}
+
+// Unobserve removes observer from the Hub. observer must be comparable,
+// such as a pointer, to be found. It may still receive notifications that
+// were queued before Unobserve was called.
+func (h *Hub) Unobserve(observer HubObserver) {
+	h.notifications.mu.Lock()
+	defer h.notifications.mu.Unlock()
+	for i, existing := range h.notifications.observers {
+		if existing.observer == observer {
+			h.notifications.observers = append(h.notifications.observers[:i:i], h.notifications.observers[i+1:]...)
+			return
+		}
+	}
+}
+
+// DefaultObserverBuffer is how many notifications a Hub queues for its
+// observers, unless Hub.ObserverBuffer says otherwise.
+const DefaultObserverBuffer = 1024
+
+// timedObserver is implemented by HubObservers that need to know when
+// each notification happened on the Hub's Clock, such as Recorder. They
+// cannot tell the time themselves, since notifications are delivered
+// after the fact.
+type timedObserver interface {
+	// at returns the observer to notify of something that happened at t.
+	at(t time.Time) HubObserver
+}
+
+// notificationQueue is a queue of notifications, delivered in order on a
+// dedicated goroutine. Enqueuing never blocks, so the Hub may enqueue from
+// its Run goroutine. Observer notifications are dropped while the queue is
+// full, except for lossless observers; calls and OnClose never are.
+type notificationQueue struct {
+	// hub is the Hub whose notifications are queued.
+	hub *Hub
+	// mu guards all fields below.
+	mu sync.Mutex
+	// ready is signalled when pending becomes non-empty or the queue closes.
+	ready *sync.Cond
+	// observers are the observers added by Observe.
+	observers []observerEntry
+	// pending are the notifications not yet delivered.
+	pending []notification
+	// droppable is how many of pending may be dropped.
+	droppable int
+	// closed is true once no more notifications will be enqueued.
+	closed bool
+}
+
+// observerEntry is an observer added by Observe.
+type observerEntry struct {
+	observer HubObserver
+	// lossless is true if the observer's notifications are never dropped.
+	lossless bool
+}
+
+// notification is a queued notification.
+type notification struct {
+	// f delivers the notification.
+	f func()
+	// droppable is true if the notification counts towards the queue's
+	// bound.
+	droppable bool
+}
+
+func newNotificationQueue(hub *Hub) *notificationQueue {
+	queue := &notificationQueue{hub: hub}
+	queue.ready = sync.NewCond(&queue.mu)
+	return queue
+}
+
+// notify enqueues a call of f for every observer. While the queue is full,
+// only lossless observers are notified.
+func (q *notificationQueue) notify(f func(HubObserver)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.observers) == 0 || q.closed {
+		return
+	}
+	if q.droppable >= q.hub.observerBuffer() {
+		q.hub.metrics().NotificationDropped(q.hub.Key)
+		if observe := q.observe(f, true); observe != nil {
+			q.enqueue(observe, false)
+		}
+		return
+	}
+	q.enqueue(q.observe(f, false), true)
+}
+
+// observe returns a function calling f for every current observer, or only
+// the lossless ones if losslessOnly is true, passing timedObservers the
+// current time. Returns nil if there are no such observers. q.mu must be
+// held.
+func (q *notificationQueue) observe(f func(HubObserver), losslessOnly bool) func() {
+	var observers []HubObserver
+	for _, entry := range q.observers {
+		if entry.lossless || !losslessOnly {
+			observers = append(observers, entry.observer)
+		}
+	}
+	if len(observers) == 0 {
+		return nil
+	}
+	now := q.hub.clock().Now()
+	return func() {
+		for _, observer := range observers {
+			if timed, ok := observer.(timedObserver); ok {
+				observer = timed.at(now)
+			}
+			f(observer)
+		}
+	}
+}
+
+// call enqueues a call of f, regardless of whether there are observers.
+func (q *notificationQueue) call(f func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.enqueue(f, false)
+	}
+}
+
+// enqueue appends f to pending. q.mu must be held.
+func (q *notificationQueue) enqueue(f func(), droppable bool) {
+	q.pending = append(q.pending, notification{f, droppable})
+	if droppable {
+		q.droppable++
+	}
+	q.ready.Signal()
+}
+
+// close notifies every observer that the Hub closed, and stops the queue
+// once every pending notification is delivered.
+func (q *notificationQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.observers) > 0 {
+		q.enqueue(q.observe(HubObserver.OnClose, false), false)
+	}
+	q.closed = true
+	q.ready.Signal()
+}
+
+// run delivers notifications until the queue is closed and drained.
+func (q *notificationQueue) run() {
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 && !q.closed {
+			q.ready.Wait()
+		}
+		if len(q.pending) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		pending := q.pending
+		q.pending = nil
+		q.droppable = 0
+		q.mu.Unlock()
+		for _, notification := range pending {
+			notification.f()
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package websocket
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// orderObserver records every notification it receives, in order.
+type orderObserver struct {
+	// mu guards notifications.
+	mu sync.Mutex
+	// notifications describe the notifications received.
+	notifications []string
+	// entered receives a value when OnRegister is first called.
+	entered chan struct{}
+	// release blocks OnRegister until it is closed, if not nil.
+	release chan struct{}
+	// closed is closed by OnClose.
+	closed chan struct{}
+}
+
+func newOrderObserver() *orderObserver {
+	return &orderObserver{entered: make(chan struct{}, 1), closed: make(chan struct{})}
+}
+
+func (o *orderObserver) record(format string, args ...any) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.notifications = append(o.notifications, fmt.Sprintf(format, args...))
+}
+
+func (o *orderObserver) OnRegister(client Client, id string) {
+	o.record("register %s", id)
+	select {
+	case o.entered <- struct{}{}:
+	default:
+	}
+	if o.release != nil {
+		<-o.release
+	}
+}
+
+func (o *orderObserver) OnUnregister(client Client, id string, reason UnregisterReason) {
+	o.record("unregister %s %s", id, reason)
+}
+
+func (o *orderObserver) OnBroadcast(event ClientEvent, senderID string) {
+	o.record("broadcast %s from %s", event.Event.Name, senderID)
+}
+
+func (o *orderObserver) OnDrop(client Client, id string) {
+	o.record("drop %s", id)
+}
+
+func (o *orderObserver) OnIdleTimeout() {
+	o.record("idle timeout")
+}
+
+func (o *orderObserver) OnClose() {
+	o.record("close")
+	close(o.closed)
+}
+
+// wait returns the notifications received once OnClose has been called.
+func (o *orderObserver) wait(t *testing.T) []string {
+	t.Helper()
+	select {
+	case <-o.closed:
+	case <-time.After(time.Second):
+		t.Fatal("observer was not closed")
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.notifications
+}
+
+// losslessObserver is an orderObserver whose notifications are never
+// dropped.
+type losslessObserver struct {
+	*orderObserver
+}
+
+func (losslessObserver) Lossless() bool {
+	return true
+}
+
+func TestHubObserverOrder(t *testing.T) {
+	hub := NewHub()
+	observer := newOrderObserver()
+	hub.Observe(observer)
+	go hub.Run()
+
+	a, b := newReplayClient("a"), newReplayClient("b")
+	hub.Register(a, ClientRegistrationOptions{})
+	hub.Register(b, ClientRegistrationOptions{})
+	hub.Broadcast(a, "chat", []byte(`"hello"`))
+	hub.Unregister(b)
+	hub.Close()
+
+	expected := []string{
+		"register a",
+		"register b",
+		"broadcast chat from a",
+		"unregister b requested",
+		"unregister a hub closed",
+		"close",
+	}
+	if notifications := observer.wait(t); !slices.Equal(notifications, expected) {
+		t.Errorf("expected notifications %q, got %q", expected, notifications)
+	}
+}
+
+// TestHubObserverBuffer checks that notifications for a slow observer are
+// dropped and counted once ObserverBuffer are queued, but OnClose and the
+// notifications of lossless observers are not.
+func TestHubObserverBuffer(t *testing.T) {
+	metrics := NewMemoryMetrics()
+	hub := NewHub()
+	hub.Metrics = metrics
+	hub.ObserverBuffer = 2
+	observer := newOrderObserver()
+	observer.release = make(chan struct{})
+	hub.Observe(observer)
+	lossless := losslessObserver{newOrderObserver()}
+	hub.Observe(lossless)
+	go hub.Run()
+	defer hub.Close()
+
+	hub.Register(newReplayClient("a"), ClientRegistrationOptions{})
+	select {
+	case <-observer.entered:
+	case <-time.After(time.Second):
+		t.Fatal("observer was not notified")
+	}
+	// The observer is blocked notifying a's registration, so b and c fill
+	// the queue and d is dropped.
+	for _, id := range []string{"b", "c", "d"} {
+		hub.Register(newReplayClient(id), ClientRegistrationOptions{})
+	}
+	hub.query(func() {})
+	if dropped := metrics.Hubs()[""].NotificationsDropped; dropped != 1 {
+		t.Errorf("expected 1 dropped notification, got %d", dropped)
+	}
+
+	close(observer.release)
+	hub.Close()
+	notifications := observer.wait(t)
+	expected := []string{"register a", "register b", "register c"}
+	if !slices.Equal(notifications[:len(expected)], expected) {
+		t.Errorf("expected notifications to begin with %q, got %q", expected, notifications)
+	}
+	if last := notifications[len(notifications)-1]; last != "close" {
+		t.Errorf("expected the last notification to be close, got %q", last)
+	}
+	expected = []string{"register a", "register b", "register c", "register d"}
+	if notifications := lossless.wait(t); !slices.Equal(notifications[:len(expected)], expected) {
+		t.Errorf("expected the lossless observer's notifications to begin with %q, got %q", expected, notifications)
+	}
+}
+
+// TestOnCloseCallbackOffRunGoroutine checks that a client's OnClose
+// callback is called off the Hub's Run goroutine, after the client is
+// unregistered, so it may query the Hub.
+func TestOnCloseCallbackOffRunGoroutine(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Close()
+
+	remaining := make(chan []ClientInfo, 1)
+	a, b := newReplayClient("a"), newReplayClient("b")
+	hub.Register(a, ClientRegistrationOptions{OnClose: func(hub *Hub) {
+		clients, err := hub.Clients()
+		if err != nil {
+			t.Error(err)
+		}
+		remaining <- clients
+	}})
+	hub.Register(b, ClientRegistrationOptions{})
+	hub.Unregister(a)
+
+	select {
+	case clients := <-remaining:
+		if len(clients) != 1 || clients[0].ID != "b" {
+			t.Errorf("expected only b to remain, got %+v", clients)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnClose was not called")
+	}
+}
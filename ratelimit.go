@@ -0,0 +1,169 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimitPolicy determines what a WebsocketClient does with an inbound
+// event that exceeds its rate limit.
+type RateLimitPolicy int
+
+const (
+	// RateLimitDrop silently discards throttled events.
+	RateLimitDrop RateLimitPolicy = iota
+	// RateLimitError discards throttled events and sends an ErrorEventName
+	// event back to the client that sent them.
+	RateLimitError
+	// RateLimitDisconnect closes the connection with close code 1008
+	// (policy violation) the first time an event is throttled.
+	RateLimitDisconnect
+)
+
+func (p RateLimitPolicy) String() string {
+	switch p {
+	case RateLimitDrop:
+		return "drop"
+	case RateLimitError:
+		return "error"
+	case RateLimitDisconnect:
+		return "disconnect"
+	}
+	return "unknown"
+}
+
+// RateLimit configures a token bucket. The bucket holds at most Burst
+// tokens and is refilled at Rate tokens per second. Every event consumes
+// one token. A RateLimit with a non-positive Rate is unlimited.
+type RateLimit struct {
+	// Rate is the number of events per second the bucket replenishes.
+	Rate float64
+	// Burst is the maximum number of events allowed in quick succession.
+	// Values less than 1 are treated as 1.
+	Burst int
+}
+
+// RateLimitOptions configure the rate limiting of events sent by a
+// WebsocketClient to its Hub.
+type RateLimitOptions struct {
+	// Global limits all events sent by the client, regardless of name.
+	Global RateLimit
+	// PerEvent limits events with a specific name. An event must be
+	// allowed by both its per event limit and the global limit.
+	PerEvent map[string]RateLimit
+	// Policy determines what happens to throttled events.
+	Policy RateLimitPolicy
+}
+
+// tokenBucket is a token bucket rate limiter. tokenBucket is not safe
+// for concurrent use.
+type tokenBucket struct {
+	// rate is the number of tokens added per second.
+	rate float64
+	// burst is the capacity of the bucket.
+	burst float64
+	// tokens is the number of tokens available as of last.
+	tokens float64
+	// last is the time tokens was last updated.
+	last time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: limit.Rate, burst: burst, tokens: burst, last: now}
+}
+
+// take consumes a token, returning true if one was available.
+func (b *tokenBucket) take(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refund returns a token consumed by take.
+func (b *tokenBucket) refund() {
+	if b == nil {
+		return
+	}
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// rateLimiter applies RateLimitOptions to the events of one connection.
+type rateLimiter struct {
+	// policy is the policy applied to throttled events.
+	policy RateLimitPolicy
+
+	// mu guards all fields below. allow is only called from readPump, but
+	// the throttle counts may be read from any goroutine.
+	mu sync.Mutex
+	// global limits all events.
+	global *tokenBucket
+	// perEvent limits individual event names.
+	perEvent map[string]*tokenBucket
+	// throttled counts the events throttled, keyed by event name.
+	throttled map[string]uint64
+}
+
+func newRateLimiter(options RateLimitOptions, now time.Time) *rateLimiter {
+	limiter := &rateLimiter{
+		policy:    options.Policy,
+		global:    newTokenBucket(options.Global, now),
+		perEvent:  make(map[string]*tokenBucket),
+		throttled: make(map[string]uint64),
+	}
+	for eventName, limit := range options.PerEvent {
+		if bucket := newTokenBucket(limit, now); bucket != nil {
+			limiter.perEvent[eventName] = bucket
+		}
+	}
+	return limiter
+}
+
+// allow returns true if an event named eventName may be sent at now,
+// consuming tokens if so. Throttled events are counted.
+func (r *rateLimiter) allow(eventName string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bucket := r.perEvent[eventName]
+	if bucket.take(now) {
+		if r.global.take(now) {
+			return true
+		}
+		// Don't charge the event's own bucket for an event that was never sent.
+		bucket.refund()
+	}
+	r.throttled[eventName]++
+	return false
+}
+
+// throttledEvents returns a copy of the throttle counts.
+func (r *rateLimiter) throttledEvents() map[string]uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[string]uint64, len(r.throttled))
+	for eventName, count := range r.throttled {
+		counts[eventName] = count
+	}
+	return counts
+}
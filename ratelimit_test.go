@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Unix(0, 0)
+	bucket := newTokenBucket(RateLimit{Rate: 2, Burst: 3}, start)
+	for i := range 3 {
+		if !bucket.take(start) {
+			t.Fatalf("expected token %d of the burst to be available", i)
+		}
+	}
+	if bucket.take(start) {
+		t.Fatal("expected the bucket to be empty after its burst")
+	}
+	// Two tokens are added per second.
+	if bucket.take(start.Add(400 * time.Millisecond)) {
+		t.Error("expected no token after 400ms")
+	}
+	if !bucket.take(start.Add(500 * time.Millisecond)) {
+		t.Error("expected a token after 500ms")
+	}
+	// Refilling stops at the burst.
+	later := start.Add(time.Hour)
+	for i := range 3 {
+		if !bucket.take(later) {
+			t.Fatalf("expected token %d of the refilled burst to be available", i)
+		}
+	}
+	if bucket.take(later) {
+		t.Error("expected the refilled bucket to hold at most its burst")
+	}
+	// Time going backwards adds no tokens.
+	if bucket.take(start) {
+		t.Error("expected no token at an earlier time")
+	}
+}
+
+func TestTokenBucketLimits(t *testing.T) {
+	now := time.Unix(0, 0)
+	if bucket := newTokenBucket(RateLimit{Rate: 0, Burst: 10}, now); bucket != nil || !bucket.take(now) {
+		t.Error("expected a non-positive rate to be unlimited")
+	}
+	bucket := newTokenBucket(RateLimit{Rate: 1, Burst: 0}, now)
+	if !bucket.take(now) || bucket.take(now) {
+		t.Error("expected a burst of 0 to be treated as 1")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter(RateLimitOptions{
+		Global: RateLimit{Rate: 1, Burst: 3},
+		PerEvent: map[string]RateLimit{
+			"typing": {Rate: 1, Burst: 1},
+			"free":   {},
+		},
+	}, now)
+	allowed := func(eventName string) bool { return limiter.allow(eventName, now) }
+
+	if !allowed("typing") {
+		t.Fatal("expected the first typing event to be allowed")
+	}
+	// The per event limit applies before the global one.
+	if allowed("typing") {
+		t.Fatal("expected the second typing event to be throttled by its own limit")
+	}
+	if !allowed("chat") || !allowed("free") {
+		t.Fatal("expected other events to share the rest of the global burst")
+	}
+	// The global limit applies to events with their own limit too, and
+	// refunds their own bucket when it throttles them.
+	now = now.Add(time.Second)
+	if !allowed("chat") {
+		t.Fatal("expected a refilled global token")
+	}
+	if allowed("typing") {
+		t.Fatal("expected a typing event to be throttled by the global limit")
+	}
+	now = now.Add(time.Second)
+	if !allowed("typing") {
+		t.Fatal("expected the typing bucket to have been refunded")
+	}
+
+	expected := map[string]uint64{"typing": 2}
+	if throttled := limiter.throttledEvents(); !maps.Equal(throttled, expected) {
+		t.Errorf("expected throttle counts %v, got %v", expected, throttled)
+	}
+}
@@ -0,0 +1,302 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// RecordKind is the kind of a Record.
+type RecordKind string
+
+const (
+	// RecordRegister records a client being registered.
+	RecordRegister RecordKind = "register"
+	// RecordUnregister records a client being removed.
+	RecordUnregister RecordKind = "unregister"
+	// RecordBroadcast records an event being broadcast.
+	RecordBroadcast RecordKind = "broadcast"
+)
+
+// Record is one line of a recording made by a Recorder.
+type Record struct {
+	// Time is the time the record happened on the Hub's Clock.
+	Time time.Time `json:"time"`
+	// Kind is the kind of record.
+	Kind RecordKind `json:"kind"`
+	// Client is the ID of the client registered, unregistered, or sending
+	// the event. Empty for events sent with BroadcastAll.
+	Client string `json:"client,omitempty"`
+	// Reason is why the client was unregistered, for RecordUnregister.
+	Reason string `json:"reason,omitempty"`
+	// Event is the event broadcast, for RecordBroadcast.
+	Event *Event `json:"event,omitempty"`
+}
+
+// Recorder is a HubObserver that writes every register, unregister and
+// broadcast of a Hub to an io.Writer as JSON lines, one Record per line.
+// Add it to a Hub with Observe before running the Hub, and play the
+// recording back with Replay. A Recorder is a LosslessObserver, so the Hub
+// never drops its notifications and the recording is complete.
+type Recorder struct {
+	BaseHubObserver
+
+	// mu guards encoder and err.
+	mu sync.Mutex
+	// encoder writes records to the Recorder's writer.
+	encoder *json.Encoder
+	// err is the first error writing a record.
+	err error
+	// closed is closed when the Hub closes.
+	closed chan struct{}
+	// closeOnce guards closing closed.
+	closeOnce sync.Once
+}
+
+// NewRecorder constructs a Recorder writing to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{encoder: json.NewEncoder(w), closed: make(chan struct{})}
+}
+
+// write writes record, remembering the first error.
+func (r *Recorder) write(record Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.encoder.Encode(record)
+}
+
+// Err returns the first error writing a record, if any. Once a write
+// fails, no further records are written.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Wait blocks until the observed Hub has closed and every record has been
+// written, then returns Err.
+func (r *Recorder) Wait() error {
+	<-r.closed
+	return r.Err()
+}
+
+// Lossless implements LosslessObserver, since a replay of a recording
+// missing records would not reproduce the session.
+func (r *Recorder) Lossless() bool {
+	return true
+}
+
+// at implements timedObserver, so records are stamped with the time they
+// happened on the Hub's Clock rather than when they were delivered.
+func (r *Recorder) at(t time.Time) HubObserver {
+	return recorderAt{r, t}
+}
+
+// The Hub notifies a Recorder through at. Notified directly, a Recorder
+// stamps records with the current time.
+
+func (r *Recorder) OnRegister(client Client, id string) {
+	r.at(time.Now()).OnRegister(client, id)
+}
+
+func (r *Recorder) OnUnregister(client Client, id string, reason UnregisterReason) {
+	r.at(time.Now()).OnUnregister(client, id, reason)
+}
+
+func (r *Recorder) OnBroadcast(event ClientEvent, senderID string) {
+	r.at(time.Now()).OnBroadcast(event, senderID)
+}
+
+func (r *Recorder) OnClose() {
+	r.closeOnce.Do(func() { close(r.closed) })
+}
+
+// recorderAt is a Recorder notified of something that happened at time.
+type recorderAt struct {
+	*Recorder
+	time time.Time
+}
+
+func (r recorderAt) OnRegister(client Client, id string) {
+	r.write(Record{Time: r.time, Kind: RecordRegister, Client: id})
+}
+
+func (r recorderAt) OnUnregister(client Client, id string, reason UnregisterReason) {
+	r.write(Record{Time: r.time, Kind: RecordUnregister, Client: id, Reason: reason.String()})
+}
+
+func (r recorderAt) OnBroadcast(event ClientEvent, senderID string) {
+	r.write(Record{Time: r.time, Kind: RecordBroadcast, Client: senderID, Event: &event.Event})
+}
+
+// ReplayOptions configure Replay.
+type ReplayOptions struct {
+	// Speed multiplies the pace of the recording: 1 replays it in real
+	// time, and 10 replays it ten times faster. If zero or negative, the
+	// recording is replayed as fast as possible.
+	Speed float64
+	// Registration are the options every fake client is registered with.
+	// Recordings do not capture the options the original clients were
+	// registered with.
+	Registration ClientRegistrationOptions
+}
+
+// ReplayClient is the fake Client standing in for a recorded client during
+// Replay. It records every event it receives. ReplayClient is safe for
+// concurrent use.
+type ReplayClient struct {
+	// id is the recorded client's ID.
+	id string
+	// send is the channel receiving events from the Hub.
+	send chan ClientEvent
+	// mu guards received.
+	mu sync.Mutex
+	// received are the events received so far.
+	received []ClientEvent
+	// closed is closed once the Hub closes the client and every event has
+	// been received.
+	closed chan struct{}
+}
+
+func newReplayClient(id string) *ReplayClient {
+	client := &ReplayClient{
+		id:     id,
+		send:   make(chan ClientEvent, 256),
+		closed: make(chan struct{}),
+	}
+	go client.receive()
+	return client
+}
+
+// receive records events until the client is closed.
+func (c *ReplayClient) receive() {
+	defer close(c.closed)
+	for clientEvent := range c.send {
+		c.mu.Lock()
+		c.received = append(c.received, clientEvent)
+		c.mu.Unlock()
+	}
+}
+
+// ID returns the recorded client's ID, so the Hub identifies the fake
+// client as the original.
+func (c *ReplayClient) ID() string {
+	return c.id
+}
+
+func (c *ReplayClient) Send() chan<- ClientEvent {
+	return c.send
+}
+
+func (c *ReplayClient) Close() {
+	close(c.send)
+}
+
+// Received returns the events the client has received so far.
+func (c *ReplayClient) Received() []ClientEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ClientEvent(nil), c.received...)
+}
+
+// Closed returns a channel that is closed once the Hub has closed the
+// client and Received reports every event it was sent.
+func (c *ReplayClient) Closed() <-chan struct{} {
+	return c.closed
+}
+
+// Replay reads a recording made by a Recorder from r and plays it back
+// against hub, which should be freshly constructed and running. Each
+// recorded client is played by a ReplayClient with the recorded ID, which
+// is registered, broadcasts, and is unregistered as the original was.
+// Clients that only appear as senders, because they registered before the
+// recording began, are registered before their first broadcast. Clients
+// removed because the Hub closed are not unregistered, and hub is never
+// closed; close it when Replay returns to end the replay.
+//
+// Replay waits between records on hub's Clock, so a replay against a Hub
+// with a FakeClock only progresses as the clock is advanced.
+//
+// Replay returns the fake clients by ID once the recording is exhausted,
+// or an error if the recording is malformed or ctx is done. If an ID was
+// registered more than once, the fake client of its last registration is
+// returned.
+func Replay(ctx context.Context, r io.Reader, hub *Hub, options ReplayOptions) (map[string]*ReplayClient, error) {
+	clients := make(map[string]*ReplayClient)
+	// registered are the IDs of the fake clients registered with hub.
+	registered := make(map[string]bool)
+	client := func(id string) *ReplayClient {
+		if !registered[id] {
+			clients[id] = newReplayClient(id)
+			registered[id] = true
+			hub.Register(clients[id], options.Registration)
+		}
+		return clients[id]
+	}
+
+	decoder := json.NewDecoder(r)
+	var previous time.Time
+	for line := 1; ; line++ {
+		var record Record
+		if err := decoder.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				return clients, nil
+			}
+			return clients, fmt.Errorf("decoding record %d: %w", line, err)
+		}
+		if options.Speed > 0 && !previous.IsZero() {
+			if err := sleepContext(ctx, hub.clock(), time.Duration(float64(record.Time.Sub(previous))/options.Speed)); err != nil {
+				return clients, err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return clients, err
+		}
+		previous = record.Time
+
+		switch record.Kind {
+		case RecordRegister:
+			client(record.Client)
+		case RecordUnregister:
+			if record.Reason == UnregisterHubClosed.String() || !registered[record.Client] {
+				continue
+			}
+			hub.Unregister(clients[record.Client])
+			delete(registered, record.Client)
+		case RecordBroadcast:
+			if record.Event == nil {
+				return clients, fmt.Errorf("record %d: broadcast has no event", line)
+			}
+			if record.Client == "" {
+				hub.BroadcastAll(record.Event.Name, record.Event.Data)
+			} else {
+				hub.Broadcast(client(record.Client), record.Event.Name, record.Event.Data)
+			}
+		default:
+			return clients, fmt.Errorf("record %d: unknown kind %q", line, record.Kind)
+		}
+	}
+}
+
+// sleepContext sleeps for d on clock, returning early with ctx's error if
+// ctx is done first.
+func sleepContext(ctx context.Context, clock Clock, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,148 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestRecordReplay records a Hub running on a FakeClock and replays the
+// recording against another, checking that records are stamped with the
+// Hub's Clock and that the replay reproduces the recorded traffic.
+func TestRecordReplay(t *testing.T) {
+	start := time.Unix(1000, 0)
+	var recording bytes.Buffer
+	recorder := NewRecorder(&recording)
+	clock := NewFakeClock(start)
+	hub := NewHub()
+	hub.Clock = clock
+	hub.Observe(recorder)
+	go hub.Run()
+
+	// Register and friends return once the Run goroutine has received them,
+	// so an empty query waits for it to finish before advancing the clock.
+	settle := func() { hub.query(func() {}) }
+	a, b := newReplayClient("a"), newReplayClient("b")
+	hub.Register(a, ClientRegistrationOptions{})
+	hub.Register(b, ClientRegistrationOptions{})
+	settle()
+	clock.Advance(2 * time.Second)
+	hub.Broadcast(a, "chat", []byte(`"hello"`))
+	settle()
+	clock.Advance(3 * time.Second)
+	hub.Unregister(b)
+	settle()
+	hub.Close()
+	if err := recorder.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		kind   RecordKind
+		client string
+		time   time.Time
+	}{
+		{RecordRegister, "a", start},
+		{RecordRegister, "b", start},
+		{RecordBroadcast, "a", start.Add(2 * time.Second)},
+		{RecordUnregister, "b", start.Add(5 * time.Second)},
+		{RecordUnregister, "a", start.Add(5 * time.Second)},
+	}
+	decoder := json.NewDecoder(bytes.NewReader(recording.Bytes()))
+	for i, expected := range expected {
+		var record Record
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("decoding record %d: %v", i, err)
+		}
+		if record.Kind != expected.kind || record.Client != expected.client || !record.Time.Equal(expected.time) {
+			t.Errorf("expected record %d to be %s of %q at %v, got %s of %q at %v", i, expected.kind, expected.client, expected.time, record.Kind, record.Client, record.Time)
+		}
+	}
+	if decoder.More() {
+		t.Error("unexpected records after the hub closed")
+	}
+
+	replayClock := NewFakeClock(time.Unix(0, 0))
+	replayHub := NewHub()
+	replayHub.Clock = replayClock
+	go replayHub.Run()
+	defer replayHub.Close()
+	type result struct {
+		clients map[string]*ReplayClient
+		err     error
+	}
+	results := make(chan result, 1)
+	go func() {
+		clients, err := Replay(context.Background(), bytes.NewReader(recording.Bytes()), replayHub, ReplayOptions{Speed: 1})
+		results <- result{clients, err}
+	}()
+	// The replay waits on the clock before the broadcast and before the
+	// unregister, alongside the Hub's idle timeout ticker.
+	for _, d := range []time.Duration{2 * time.Second, 3 * time.Second} {
+		replayClock.BlockUntil(2)
+		replayClock.Advance(d)
+	}
+	var replayed result
+	select {
+	case replayed = <-results:
+	case <-time.After(time.Second):
+		t.Fatal("replay did not finish")
+	}
+	if replayed.err != nil {
+		t.Fatal(replayed.err)
+	}
+	replayB := replayed.clients["b"]
+	if replayB == nil {
+		t.Fatal("expected client b to be replayed")
+	}
+	select {
+	case <-replayB.Closed():
+	case <-time.After(time.Second):
+		t.Fatal("expected client b to be unregistered")
+	}
+	received := replayB.Received()
+	if len(received) != 1 || received[0].Event.Name != "chat" || string(received[0].Event.Data) != `"hello"` {
+		t.Errorf("expected client b to receive the chat event, got %+v", received)
+	}
+	if received := replayed.clients["a"].Received(); len(received) != 0 {
+		t.Errorf("expected client a to receive nothing, got %+v", received)
+	}
+}
+
+// TestRecorderLossless checks that a Recorder records every notification
+// even while a slow observer fills the Hub's notification queue.
+func TestRecorderLossless(t *testing.T) {
+	var recording bytes.Buffer
+	recorder := NewRecorder(&recording)
+	hub := NewHub()
+	hub.ObserverBuffer = 1
+	slow := newOrderObserver()
+	slow.release = make(chan struct{})
+	hub.Observe(slow)
+	hub.Observe(recorder)
+	go hub.Run()
+
+	ids := []string{"a", "b", "c", "d"}
+	for _, id := range ids {
+		hub.Register(newReplayClient(id), ClientRegistrationOptions{})
+	}
+	hub.query(func() {})
+	close(slow.release)
+	hub.Close()
+	if err := recorder.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(recording.Bytes()))
+	for _, id := range ids {
+		var record Record
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Kind != RecordRegister || record.Client != id {
+			t.Errorf("expected the registration of %q, got %s of %q", id, record.Kind, record.Client)
+		}
+	}
+}
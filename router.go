@@ -0,0 +1,63 @@
+package websocket
+
+import "sync"
+
+// Router is a Client that dispatches the events it receives to handlers
+// registered by event name pattern. Register a Router with a Hub like any
+// other Client, then call Run to begin dispatching.
+type Router struct {
+	// send is the channel receiving events from the Hub.
+	send chan ClientEvent
+
+	// mu guards handlers.
+	mu sync.RWMutex
+	// handlers are the registered handlers, keyed by pattern.
+	handlers *patternTrie[Handler]
+}
+
+// NewRouter constructs a Router with no handlers.
+func NewRouter() *Router {
+	return &Router{
+		send:     make(chan ClientEvent, 256),
+		handlers: newPatternTrie[Handler](),
+	}
+}
+
+// Handle registers handler to receive every event whose name matches
+// pattern, as understood by MatchEventName. When several patterns match an
+// event, their handlers are called in the order they were registered.
+func (r *Router) Handle(pattern string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers.add(pattern, handler)
+}
+
+func (r *Router) Send() chan<- ClientEvent {
+	return r.send
+}
+
+func (r *Router) Close() {
+	close(r.send)
+}
+
+// Run dispatches events to handlers until the Router is closed. Handlers
+// are called on the Run goroutine.
+func (r *Router) Run() {
+	for clientEvent := range r.send {
+		r.Dispatch(clientEvent)
+	}
+}
+
+// Dispatch calls the handlers matching clientEvent on the calling
+// goroutine.
+func (r *Router) Dispatch(clientEvent ClientEvent) {
+	r.mu.RLock()
+	var handlers []Handler
+	r.handlers.match(clientEvent.Event.Name, func(handler Handler) {
+		handlers = append(handlers, handler)
+	})
+	r.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(clientEvent)
+	}
+}
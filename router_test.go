@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// routeLog returns a Handler appending name to *calls.
+func routeLog(calls *[]string, name string) Handler {
+	return func(ClientEvent) {
+		*calls = append(*calls, name)
+	}
+}
+
+func TestRouterDispatch(t *testing.T) {
+	router := NewRouter()
+	var calls []string
+	router.Handle("chat.message", routeLog(&calls, "exact"))
+	router.Handle("chat.*", routeLog(&calls, "single"))
+	router.Handle("**", routeLog(&calls, "everything"))
+	router.Handle("chat.**", routeLog(&calls, "chat"))
+
+	tests := []struct {
+		eventName string
+		handlers  []string
+	}{
+		{"chat.message", []string{"exact", "single", "everything", "chat"}},
+		{"chat.join", []string{"single", "everything", "chat"}},
+		{"chat", []string{"everything", "chat"}},
+		{"chat.room42.message", []string{"everything", "chat"}},
+		{"news", []string{"everything"}},
+	}
+	for _, test := range tests {
+		calls = nil
+		router.Dispatch(ClientEvent{Event: Event{Name: test.eventName}})
+		if !slices.Equal(calls, test.handlers) {
+			t.Errorf("expected %q to be handled by %q, got %q", test.eventName, test.handlers, calls)
+		}
+	}
+}
+
+// TestRouterOrder checks that handlers are called in the order they were
+// registered, whichever pattern they were registered under, and that a
+// pattern may have several handlers.
+func TestRouterOrder(t *testing.T) {
+	router := NewRouter()
+	var calls []string
+	router.Handle("**", routeLog(&calls, "1"))
+	router.Handle("chat.message", routeLog(&calls, "2"))
+	router.Handle("*.message", routeLog(&calls, "3"))
+	router.Handle("chat.message", routeLog(&calls, "4"))
+	router.Handle("chat.**", routeLog(&calls, "5"))
+	router.Handle("**.message", routeLog(&calls, "6"))
+
+	router.Dispatch(ClientEvent{Event: Event{Name: "chat.message"}})
+	if expected := []string{"1", "2", "3", "4", "5", "6"}; !slices.Equal(calls, expected) {
+		t.Errorf("expected handlers %q, got %q", expected, calls)
+	}
+}
+
+func TestRouterNoMatch(t *testing.T) {
+	router := NewRouter()
+	var calls []string
+	router.Handle("chat.*", routeLog(&calls, "chat"))
+	for _, name := range []string{"chat", "chat.room.message", "news", ""} {
+		router.Dispatch(ClientEvent{Event: Event{Name: name}})
+	}
+	if len(calls) != 0 {
+		t.Errorf("expected no handlers to be called, got %q", calls)
+	}
+}
+
+// TestRouterRun checks that a Router registered with a Hub dispatches the
+// events it receives until it is closed.
+func TestRouterRun(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	router := NewRouter()
+	handled := make(chan string, 2)
+	router.Handle("chat.*", func(clientEvent ClientEvent) {
+		handled <- clientEvent.Event.Name
+	})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		router.Run()
+	}()
+	hub.Register(router, ClientRegistrationOptions{})
+	hub.BroadcastAll("news", nil)
+	hub.BroadcastAll("chat.message", nil)
+	select {
+	case name := <-handled:
+		if name != "chat.message" {
+			t.Errorf("expected chat.message to be handled, got %q", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event was not handled")
+	}
+
+	hub.Close()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return once the router was closed")
+	}
+}
@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Generate js/socket.js, js/socket.mjs and js/socket.d.ts from js/socket.ts.
+//go:generate go run generate/generate.go
+
+var (
+	//go:embed js/socket.js
+	socketJs []byte
+	//go:embed js/socket.mjs
+	socketModule []byte
+	//go:embed js/socket.d.ts
+	socketTypes []byte
+)
+
+var (
+	socketJsAsset     = newAsset("text/javascript; charset=utf-8", socketJs)
+	socketModuleAsset = newAsset("text/javascript; charset=utf-8", socketModule)
+	socketTypesAsset  = newAsset("application/typescript; charset=utf-8", socketTypes)
+)
+
+// ServeSocketModule serves the Socket class of ServeSocketJs as an ES
+// module, which exports Socket instead of declaring it globally:
+//
+//	import { Socket } from "/socket.mjs";
+//
+// Like ServeSocketJs, it is served with an ETag and gzip compression.
+func ServeSocketModule(w http.ResponseWriter, req *http.Request) error {
+	return socketModuleAsset.serve(w, req)
+}
+
+// ServeSocketTypes serves the TypeScript declarations of the module served
+// by ServeSocketModule. Serve it alongside the module as socket.d.ts, or
+// save it into a TypeScript project to type its imports of Socket.
+func ServeSocketTypes(w http.ResponseWriter, req *http.Request) error {
+	return socketTypesAsset.serve(w, req)
+}
+
+// asset is an embedded file served with an ETag for revalidation and gzip
+// compression for clients that accept it.
+type asset struct {
+	// contentType is the file's Content-Type.
+	contentType string
+	// content is the file's contents.
+	content []byte
+	// gzipped is content compressed with gzip.
+	gzipped []byte
+	// etag is the ETag of content. The ETag of gzipped is etag with a
+	// "-gzip" suffix.
+	etag string
+}
+
+func newAsset(contentType string, content []byte) *asset {
+	var gzipped bytes.Buffer
+	writer, _ := gzip.NewWriterLevel(&gzipped, gzip.BestCompression)
+	writer.Write(content)
+	writer.Close()
+	sum := sha256.Sum256(content)
+	return &asset{
+		contentType: contentType,
+		content:     content,
+		gzipped:     gzipped.Bytes(),
+		etag:        hex.EncodeToString(sum[:8]),
+	}
+}
+
+// serve writes the asset in response to req, or a 304 Not Modified
+// response if req already has the current version.
+func (a *asset) serve(w http.ResponseWriter, req *http.Request) error {
+	header := w.Header()
+	header.Set("Content-Type", a.contentType)
+	// Browsers may cache the file, but must revalidate it, so a server
+	// upgrade is picked up immediately.
+	header.Set("Cache-Control", "no-cache")
+	header.Add("Vary", "Accept-Encoding")
+
+	content, etag := a.content, a.etag
+	if req != nil && acceptsGzip(req) {
+		content, etag = a.gzipped, a.etag+"-gzip"
+		header.Set("Content-Encoding", "gzip")
+	}
+	header.Set("ETag", strconv.Quote(etag))
+	if req != nil && etagMatches(req.Header.Get("If-None-Match"), etag) {
+		header.Del("Content-Encoding")
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	header.Set("Content-Length", strconv.Itoa(len(content)))
+	if req != nil && req.Method == http.MethodHead {
+		return nil
+	}
+	_, err := w.Write(content)
+	return err
+}
+
+// acceptsGzip returns true if req accepts gzip encoded responses.
+func acceptsGzip(req *http.Request) bool {
+	for _, encoding := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		// Encodings with a quality of zero are not acceptable.
+		quality := strings.ReplaceAll(params, " ", "")
+		return quality != "q=0" && quality != "q=0.0" && quality != "q=0.00" && quality != "q=0.000"
+	}
+	return false
+}
+
+// etagMatches returns true if the If-None-Match header value ifNoneMatch
+// matches etag, which is unquoted.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strconv.Quote(etag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestSocketJsIsCurrent fails if the builds in js were not regenerated
+// after js/socket.ts changed, or were edited by hand afterwards. If tsc is
+// installed, it also checks the builds are what tsc compiles.
+func TestSocketJsIsCurrent(t *testing.T) {
+	source, err := os.ReadFile("js/socket.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp := fmt.Sprintf("// socket.ts sha256: %x\n", sha256.Sum256(source))
+	builds := map[string][]byte{
+		"js/socket.js":   socketJs,
+		"js/socket.mjs":  socketModule,
+		"js/socket.d.ts": socketTypes,
+	}
+	for name, build := range builds {
+		header, contents, _ := bytes.Cut(build, []byte("\n\n"))
+		if !bytes.Contains(header, []byte(stamp)) {
+			t.Errorf("%s is stale; run go generate after changing js/socket.ts", name)
+		}
+		if !bytes.HasSuffix(header, []byte(fmt.Sprintf("\n// output sha256: %x", sha256.Sum256(contents)))) {
+			t.Errorf("%s was edited after it was generated; run go generate", name)
+		}
+	}
+
+	if _, err := exec.LookPath("tsc"); err != nil {
+		t.Log("tsc is not installed; not comparing the builds with its output")
+		return
+	}
+	output, err := exec.Command("go", "run", "generate/generate.go", "-check").CombinedOutput()
+	if err != nil {
+		t.Errorf("%v\n%s", err, output)
+	}
+}
+
+// TestSocketJsBehavior runs js/socket_test.mjs, which tests socket.mjs and
+// socket.js against a fake WebSocket, if node is installed.
+func TestSocketJsBehavior(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	output, err := exec.Command(node, "js/socket_test.mjs").CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+}
+
+func TestServeSocketModule(t *testing.T) {
+	handlers := map[string]func(http.ResponseWriter, *http.Request) error{
+		"text/javascript":        ServeSocketModule,
+		"application/typescript": ServeSocketTypes,
+	}
+	for contentType, handler := range handlers {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		if recorder.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", recorder.Code)
+		}
+		if !strings.HasPrefix(recorder.Header().Get("Content-Type"), contentType) {
+			t.Errorf("expected Content-Type %s, got %q", contentType, recorder.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(recorder.Body.String(), "export") {
+			t.Error("expected the module to export Socket")
+		}
+	}
+}
+
+func TestServeSocketJs(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	ServeSocketJs(recorder, httptest.NewRequest(http.MethodGet, "/socket.js", nil))
+	response := recorder.Result()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", response.StatusCode)
+	}
+	if contentType := response.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/javascript") {
+		t.Errorf("unexpected Content-Type %q", contentType)
+	}
+	if response.Header.Get("Cache-Control") == "" {
+		t.Error("expected a Cache-Control header")
+	}
+	body, _ := io.ReadAll(response.Body)
+	if !bytes.Equal(body, socketJs) {
+		t.Error("served body is not socket.js")
+	}
+
+	// A request with the current ETag is not sent the file again.
+	request := httptest.NewRequest(http.MethodGet, "/socket.js", nil)
+	request.Header.Set("If-None-Match", response.Header.Get("ETag"))
+	recorder = httptest.NewRecorder()
+	ServeSocketJs(recorder, request)
+	if recorder.Code != http.StatusNotModified {
+		t.Errorf("expected status 304 for a matching ETag, got %d", recorder.Code)
+	}
+	if recorder.Body.Len() != 0 {
+		t.Error("expected an empty body for a matching ETag")
+	}
+}
+
+func TestServeSocketJsGzip(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/socket.js", nil)
+	request.Header.Set("Accept-Encoding", "deflate, gzip;q=0.8")
+	recorder := httptest.NewRecorder()
+	ServeSocketJs(recorder, request)
+	response := recorder.Result()
+	if encoding := response.Header.Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("expected gzip Content-Encoding, got %q", encoding)
+	}
+	reader, err := gzip.NewReader(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body, socketJs) {
+		t.Error("decompressed body is not socket.js")
+	}
+
+	request.Header.Set("Accept-Encoding", "gzip;q=0")
+	recorder = httptest.NewRecorder()
+	ServeSocketJs(recorder, request)
+	if encoding := recorder.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("expected no Content-Encoding when gzip is refused, got %q", encoding)
+	}
+}
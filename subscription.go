@@ -0,0 +1,201 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// SubscribeEventName is the name of the event a client sends to receive
+	// only events matching the given patterns. Its data is a pattern or an
+	// array of patterns.
+	SubscribeEventName = "$subscribe"
+	// UnsubscribeEventName is the name of the event a client sends to stop
+	// receiving events matching the given patterns. Its data is a pattern
+	// or an array of patterns.
+	UnsubscribeEventName = "$unsubscribe"
+)
+
+const (
+	// maxSubscriptionPatterns is the most patterns a peer may send in one
+	// SubscribeEventName or UnsubscribeEventName event.
+	maxSubscriptionPatterns = 32
+	// maxPatternSegments is the most segments a pattern sent by a peer may
+	// have. Patterns are matched on the Hub's Run goroutine, so peers must
+	// not be able to make matching arbitrarily expensive.
+	maxPatternSegments = 16
+	// maxPeerSubscriptions is the most patterns a peer's subscriptions and
+	// exclusions may add up to, so that a peer cannot grow its client's
+	// memory and the cost of matching without limit.
+	maxPeerSubscriptions = 256
+)
+
+// subscriptionSet decides which events a client receives by name. Patterns
+// are hierarchical event name patterns as understood by MatchEventName. A
+// client with no subscriptions receives every event not matching one of its
+// exclusions. A client with subscriptions receives only events matching one
+// of them (and none of its exclusions). subscriptionSet is not safe for
+// concurrent use; the Hub only accesses it from the Run goroutine.
+type subscriptionSet struct {
+	// include are the patterns the client subscribed to.
+	include *patternTrie[struct{}]
+	// exclude are the patterns the client unsubscribed from without first
+	// subscribing to them.
+	exclude *patternTrie[struct{}]
+}
+
+func newSubscriptionSet(patterns []string) *subscriptionSet {
+	set := &subscriptionSet{include: newPatternTrie[struct{}](), exclude: newPatternTrie[struct{}]()}
+	set.subscribe(patterns)
+	return set
+}
+
+// subscribe adds patterns to the set, removing them from the exclusions.
+func (s *subscriptionSet) subscribe(patterns []string) {
+	for _, pattern := range patterns {
+		s.exclude.remove(pattern)
+		s.include.set(pattern, struct{}{})
+	}
+}
+
+// unsubscribe removes patterns from the set. Patterns that were never
+// subscribed to are excluded instead.
+func (s *subscriptionSet) unsubscribe(patterns []string) {
+	for _, pattern := range patterns {
+		if s.include.has(pattern) {
+			s.include.remove(pattern)
+		} else {
+			s.exclude.set(pattern, struct{}{})
+		}
+	}
+}
+
+// len returns how many patterns the set subscribes to or excludes.
+func (s *subscriptionSet) len() int {
+	return s.include.len() + s.exclude.len()
+}
+
+// growth returns how much subscribing to patterns, or unsubscribing from
+// them if subscribe is false, would change len.
+func (s *subscriptionSet) growth(patterns []string, subscribe bool) int {
+	growth := 0
+	changed := make(map[string]bool)
+	for _, pattern := range patterns {
+		if changed[pattern] {
+			continue
+		}
+		changed[pattern] = true
+		switch {
+		case subscribe && s.exclude.has(pattern):
+			// The exclusion is replaced by the subscription.
+		case subscribe && !s.include.has(pattern):
+			growth++
+		case !subscribe && s.include.has(pattern):
+			growth--
+		case !subscribe && !s.exclude.has(pattern):
+			growth++
+		}
+	}
+	return growth
+}
+
+// matches returns true if an event named eventName should be delivered.
+func (s *subscriptionSet) matches(eventName string) bool {
+	if s.exclude.matchesAny(eventName) {
+		return false
+	}
+	return s.include.empty() || s.include.matchesAny(eventName)
+}
+
+// subscriptionChange is a request to change a client's subscriptions.
+type subscriptionChange struct {
+	// client is the client whose subscriptions change.
+	client Client
+	// patterns are the patterns subscribed to or unsubscribed from.
+	patterns []string
+	// subscribe is true to subscribe to patterns, false to unsubscribe.
+	subscribe bool
+}
+
+// Subscribe restricts client to receiving events whose names match one of
+// the given patterns, or any pattern it previously subscribed to. Patterns
+// are matched as by MatchEventName. Blocks until the subscription is
+// changed or the hub stops running. Does nothing if client is not
+// registered.
+func (h *Hub) Subscribe(client Client, patterns ...string) {
+	h.changeSubscriptions(subscriptionChange{client: client, patterns: patterns, subscribe: true})
+}
+
+// Unsubscribe stops client from receiving events whose names match one of
+// the given patterns. Patterns are matched as by MatchEventName. Blocks
+// until the subscription is changed or the hub stops running. Does nothing
+// if client is not registered.
+func (h *Hub) Unsubscribe(client Client, patterns ...string) {
+	h.changeSubscriptions(subscriptionChange{client: client, patterns: patterns, subscribe: false})
+}
+
+// changePeerSubscriptions applies a change to client's subscriptions
+// requested by its peer, like Subscribe or Unsubscribe. Returns an error,
+// leaving the subscriptions unchanged, if they would add up to more than
+// maxPeerSubscriptions patterns. Does nothing if client is not registered.
+func (h *Hub) changePeerSubscriptions(change subscriptionChange) error {
+	var err error
+	h.query(func() {
+		clientData, ok := h.clients[change.client]
+		if !ok {
+			return
+		}
+		if size := clientData.subscriptions.len() + clientData.subscriptions.growth(change.patterns, change.subscribe); size > maxPeerSubscriptions {
+			err = fmt.Errorf("expected at most %d subscriptions and exclusions, got %d", maxPeerSubscriptions, size)
+			return
+		}
+		h.applySubscriptionChange(change)
+	})
+	return err
+}
+
+// applySubscriptionChange changes a client's subscriptions. Must only be
+// called from the Run goroutine.
+func (h *Hub) applySubscriptionChange(change subscriptionChange) {
+	if clientData, ok := h.clients[change.client]; ok {
+		if change.subscribe {
+			clientData.subscriptions.subscribe(change.patterns)
+		} else {
+			clientData.subscriptions.unsubscribe(change.patterns)
+		}
+	}
+}
+
+// changeSubscriptions hands change to the Run goroutine.
+func (h *Hub) changeSubscriptions(change subscriptionChange) {
+	select {
+	case h.subscriptions <- change:
+	case <-h.done:
+	}
+}
+
+// decodePatterns decodes the data of a SubscribeEventName or
+// UnsubscribeEventName event, which is either a single pattern or an array
+// of patterns. Returns an error if there are more than
+// maxSubscriptionPatterns patterns, or a pattern has more than
+// maxPatternSegments segments.
+func decodePatterns(data json.RawMessage) ([]string, error) {
+	var patterns []string
+	var pattern string
+	if err := json.Unmarshal(data, &pattern); err == nil {
+		patterns = []string{pattern}
+	} else if err := json.Unmarshal(data, &patterns); err != nil {
+		return nil, errors.New("expected a pattern or an array of patterns")
+	}
+	if len(patterns) > maxSubscriptionPatterns {
+		return nil, fmt.Errorf("expected at most %d patterns, got %d", maxSubscriptionPatterns, len(patterns))
+	}
+	for _, pattern := range patterns {
+		if segments := strings.Count(pattern, eventNameSeparator) + 1; segments > maxPatternSegments {
+			return nil, fmt.Errorf("expected patterns of at most %d segments, got %q", maxPatternSegments, pattern)
+		}
+	}
+	return patterns, nil
+}
@@ -0,0 +1,222 @@
+package websocket_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/CooperCorona/websocket"
+	"github.com/CooperCorona/websocket/wstest"
+)
+
+// subscriptionFixture is a peer connected to a Hub, and an in-memory client
+// of the Hub receiving the events the peer sends.
+type subscriptionFixture struct {
+	hub  *websocket.Hub
+	conn *wstest.Conn
+	// client is the Hub's client of conn.
+	client   websocket.Client
+	listener *wstest.Client
+	// syncs counts the sync events sent, so each has a unique name.
+	syncs int
+}
+
+func newSubscriptionFixture(t *testing.T, options websocket.WebsocketOptions) *subscriptionFixture {
+	t.Helper()
+	hub := websocket.NewHub()
+	recorder := &clientRecorder{clients: make(chan websocket.Client, 2)}
+	hub.Observe(recorder)
+	server := wstest.NewServer(hub, options)
+	t.Cleanup(server.Close)
+	listener := wstest.NewClient()
+	hub.Register(listener, websocket.ClientRegistrationOptions{})
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	<-recorder.clients
+	return &subscriptionFixture{hub: hub, conn: conn, client: <-recorder.clients, listener: listener}
+}
+
+// change sends a SubscribeEventName or UnsubscribeEventName event from the
+// peer, returning once the Hub has applied it. The peer's events are
+// handled in order, so the change is applied once the listener receives
+// an event sent after it.
+func (f *subscriptionFixture) change(t *testing.T, name string, patterns any) {
+	t.Helper()
+	if err := f.conn.Send(name, patterns); err != nil {
+		t.Fatal(err)
+	}
+	f.syncs++
+	sync := fmt.Sprintf("sync%d", f.syncs)
+	if err := f.conn.Send(sync, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.listener.ExpectEvent(sync, waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// received broadcasts names, then a final "last" event every peer
+// receives, and returns the names of the events the peer received other
+// than "last" and errors.
+func (f *subscriptionFixture) received(t *testing.T, names ...string) []string {
+	t.Helper()
+	for _, name := range names {
+		f.hub.BroadcastAll(name, nil)
+	}
+	f.hub.BroadcastAll("last", nil)
+	if _, err := f.conn.ExpectEvent("last", waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	received := []string{}
+	for _, event := range f.conn.Events() {
+		if event.Name != "last" && event.Name != websocket.ErrorEventName {
+			received = append(received, event.Name)
+		}
+	}
+	return received
+}
+
+// expectError waits for the peer to receive an ErrorEventName event with
+// code.
+func (f *subscriptionFixture) expectError(t *testing.T, code string) {
+	t.Helper()
+	event, err := f.conn.ExpectEvent(websocket.ErrorEventName, waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data websocket.ErrorEventData
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Code != code {
+		t.Errorf("expected error code %q, got %+v", code, data)
+	}
+}
+
+func TestPeerSubscriptions(t *testing.T) {
+	fixture := newSubscriptionFixture(t, websocket.WebsocketOptions{})
+	fixture.change(t, websocket.SubscribeEventName, []string{"chat.*", "alerts.**", "last"})
+	// Unsubscribing from a pattern never subscribed to excludes it.
+	fixture.change(t, websocket.UnsubscribeEventName, "alerts.fire")
+	received := fixture.received(t, "chat.room", "chat.room.message", "alerts", "alerts.fire", "alerts.water.cold", "news")
+	if expected := []string{"chat.room", "alerts", "alerts.water.cold"}; !slices.Equal(received, expected) {
+		t.Errorf("expected events %q, got %q", expected, received)
+	}
+
+	// Subscribing again replaces the exclusion.
+	fixture.change(t, websocket.UnsubscribeEventName, []string{"chat.*"})
+	fixture.change(t, websocket.SubscribeEventName, "alerts.fire")
+	received = fixture.received(t, "chat.lobby", "alerts.fire")
+	if expected := []string{"chat.room", "alerts", "alerts.water.cold", "alerts.fire"}; !slices.Equal(received, expected) {
+		t.Errorf("expected events %q, got %q", expected, received)
+	}
+}
+
+// TestPeerSubscriptionLimits checks that a peer cannot send too many
+// patterns, or too long patterns, in one event, nor accumulate more than
+// 256 subscriptions and exclusions.
+func TestPeerSubscriptionLimits(t *testing.T) {
+	fixture := newSubscriptionFixture(t, websocket.WebsocketOptions{})
+	patterns := func(prefix string, n int) []string {
+		patterns := make([]string, n)
+		for i := range patterns {
+			patterns[i] = fmt.Sprintf("%s%d", prefix, i)
+		}
+		return patterns
+	}
+
+	fixture.change(t, websocket.SubscribeEventName, patterns("too.many.", 33))
+	fixture.expectError(t, websocket.ErrorCodeInvalidPayload)
+	fixture.change(t, websocket.SubscribeEventName, strings.Repeat("a.", 16)+"a")
+	fixture.expectError(t, websocket.ErrorCodeInvalidPayload)
+	fixture.change(t, websocket.SubscribeEventName, 42)
+	fixture.expectError(t, websocket.ErrorCodeInvalidPayload)
+
+	// With "last", 8 events of 32 patterns reach the limit, after which
+	// subscriptions and exclusions that would add patterns are rejected.
+	fixture.change(t, websocket.SubscribeEventName, "last")
+	for i := range 8 {
+		fixture.change(t, websocket.SubscribeEventName, patterns(fmt.Sprintf("chat.%d.", i), 32-i/7))
+	}
+	fixture.change(t, websocket.SubscribeEventName, "news")
+	fixture.expectError(t, websocket.ErrorCodeSubscriptionLimit)
+	fixture.change(t, websocket.UnsubscribeEventName, "news")
+	fixture.expectError(t, websocket.ErrorCodeSubscriptionLimit)
+	// Subscribing to a pattern again adds nothing, and unsubscribing makes
+	// room.
+	fixture.change(t, websocket.SubscribeEventName, "chat.0.0")
+	fixture.change(t, websocket.UnsubscribeEventName, "chat.0.0")
+	fixture.change(t, websocket.SubscribeEventName, "news")
+
+	received := fixture.received(t, "chat.0.0", "chat.7.30", "news", "alerts")
+	if expected := []string{"chat.7.30", "news"}; !slices.Equal(received, expected) {
+		t.Errorf("expected events %q, got %q", expected, received)
+	}
+	var errors int
+	for _, event := range fixture.conn.Events() {
+		if event.Name == websocket.ErrorEventName {
+			errors++
+		}
+	}
+	if errors != 5 {
+		t.Errorf("expected 5 errors, got %d", errors)
+	}
+}
+
+// TestSubscriptionsAndFilter checks that a client receives only events
+// both its subscriptions and its Filter accept, and that the server can
+// change its subscriptions.
+func TestSubscriptionsAndFilter(t *testing.T) {
+	fixture := newSubscriptionFixture(t, websocket.WebsocketOptions{
+		Subscriptions: []string{"chat.**", "last"},
+		Filter: func(clientEvent websocket.ClientEvent) bool {
+			return !strings.HasSuffix(clientEvent.Event.Name, ".secret")
+		},
+	})
+	received := fixture.received(t, "chat", "chat.room.message", "chat.room.secret", "news", "news.secret")
+	if expected := []string{"chat", "chat.room.message"}; !slices.Equal(received, expected) {
+		t.Errorf("expected events %q, got %q", expected, received)
+	}
+
+	fixture.hub.Unsubscribe(fixture.client, "chat.**")
+	fixture.hub.Subscribe(fixture.client, "news.*")
+	received = fixture.received(t, "chat.room.message", "news.today", "news.secret")
+	if expected := []string{"chat", "chat.room.message", "news.today"}; !slices.Equal(received, expected) {
+		t.Errorf("expected events %q, got %q", expected, received)
+	}
+}
+
+func TestIgnoreEvents(t *testing.T) {
+	hub := websocket.NewHub()
+	recorder := &clientRecorder{clients: make(chan websocket.Client, 1)}
+	hub.Observe(recorder)
+	server := wstest.NewServer(hub, websocket.WebsocketOptions{})
+	defer server.Close()
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := (<-recorder.clients).(*websocket.WebsocketClient)
+
+	// Ignored names are matched exactly, not as patterns.
+	client.IgnoreEvents("chat", "chat.*")
+	for _, name := range []string{"chat", "chat.*", "chat.message", "last"} {
+		hub.BroadcastAll(name, nil)
+	}
+	if _, err := conn.ExpectEvent("last", waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, event := range conn.Events() {
+		names = append(names, event.Name)
+	}
+	if expected := []string{"chat.message", "last"}; !slices.Equal(names, expected) {
+		t.Errorf("expected events %q, got %q", expected, names)
+	}
+}
@@ -47,6 +47,12 @@ func main() {
 		websocket.ServeSocketJs(w, req)
 		// http.ServeFile(w, req, "socket.js")
 	})
+	http.HandleFunc("/socket.mjs", func(w http.ResponseWriter, req *http.Request) {
+		websocket.ServeSocketModule(w, req)
+	})
+	http.HandleFunc("/socket.d.ts", func(w http.ResponseWriter, req *http.Request) {
+		websocket.ServeSocketTypes(w, req)
+	})
 
 	//
 	// CONNECT
@@ -182,7 +188,7 @@ func main() {
 		go func() {
 			select {
 			case _ = <-updateTimeoutTicker.C:
-				hub.CloseTimeout = time.Second * 2
+				hub.SetCloseTimeout(time.Second * 2)
 				updateTimeoutTicker.Stop()
 			}
 		}()
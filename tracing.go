@@ -0,0 +1,275 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventMeta is optional metadata carried alongside an Event's data.
+type EventMeta struct {
+	// TraceParent is a W3C Trace Context traceparent header value
+	// identifying the span that produced the event.
+	TraceParent string `json:"traceparent,omitempty"`
+	// TraceState is a W3C Trace Context tracestate header value.
+	TraceState string `json:"tracestate,omitempty"`
+}
+
+// SpanContext identifies a span in a trace, as propagated by the W3C Trace
+// Context traceparent and tracestate headers.
+type SpanContext struct {
+	// TraceID identifies the trace.
+	TraceID [16]byte
+	// SpanID identifies the span within the trace.
+	SpanID [8]byte
+	// Sampled is true if the trace is being recorded.
+	Sampled bool
+	// TraceState is vendor specific trace state.
+	TraceState string
+}
+
+// IsValid returns true if the trace and span IDs are not all zero.
+func (s SpanContext) IsValid() bool {
+	return s.TraceID != [16]byte{} && s.SpanID != [8]byte{}
+}
+
+// TraceParent formats s as a traceparent header value.
+func (s SpanContext) TraceParent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(s.TraceID[:]), hex.EncodeToString(s.SpanID[:]), flags)
+}
+
+// ParseTraceParent parses traceparent and tracestate header values into a
+// SpanContext.
+func ParseTraceParent(traceparent string, tracestate string) (SpanContext, error) {
+	var spanContext SpanContext
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || !isLowerHex(parts[0]) || parts[0] == "ff" {
+		return spanContext, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return spanContext, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return spanContext, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	if !isLowerHex(parts[1]) {
+		return spanContext, fmt.Errorf("invalid trace ID in traceparent %q", traceparent)
+	}
+	if !isLowerHex(parts[2]) {
+		return spanContext, fmt.Errorf("invalid span ID in traceparent %q", traceparent)
+	}
+	if !isLowerHex(parts[3]) {
+		return spanContext, fmt.Errorf("invalid flags in traceparent %q", traceparent)
+	}
+	// The fields are lowercase hex, so decoding them cannot fail.
+	var flags [1]byte
+	hex.Decode(spanContext.TraceID[:], []byte(parts[1]))
+	hex.Decode(spanContext.SpanID[:], []byte(parts[2]))
+	hex.Decode(flags[:], []byte(parts[3]))
+	if !spanContext.IsValid() {
+		return spanContext, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	spanContext.Sampled = flags[0]&1 == 1
+	spanContext.TraceState = tracestate
+	return spanContext, nil
+}
+
+// isLowerHex reports whether s consists only of lowercase hex digits, the
+// only digits trace context allows.
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// spanContextKey is the context key of a SpanContext.
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx carrying spanContext as the
+// current span.
+func ContextWithSpanContext(ctx context.Context, spanContext SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, spanContext)
+}
+
+// SpanContextFromContext returns the current span of ctx, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if ctx == nil {
+		return SpanContext{}, false
+	}
+	spanContext, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return spanContext, ok && spanContext.IsValid()
+}
+
+// injectMeta returns the EventMeta propagating the current span of ctx, or
+// nil if ctx has no span.
+func injectMeta(ctx context.Context) *EventMeta {
+	spanContext, ok := SpanContextFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return &EventMeta{TraceParent: spanContext.TraceParent(), TraceState: spanContext.TraceState}
+}
+
+// extractMeta returns a copy of ctx carrying the span propagated by meta.
+// Returns ctx unchanged if meta does not contain a valid span.
+func extractMeta(ctx context.Context, meta *EventMeta) context.Context {
+	if meta == nil || meta.TraceParent == "" {
+		return ctx
+	}
+	spanContext, err := ParseTraceParent(meta.TraceParent, meta.TraceState)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, spanContext)
+}
+
+// Span is a unit of work recorded by a Tracer.
+type Span interface {
+	// SpanContext identifies the span.
+	SpanContext() SpanContext
+	// SetAttribute records a key value pair on the span.
+	SetAttribute(key string, value string)
+	// End completes the span.
+	End()
+}
+
+// Tracer records spans. Hubs start a "websocket.broadcast" span for every
+// broadcast, and WebsocketClients start a "websocket.receive" span for
+// every event read from their peer and a "websocket.deliver" span for
+// every event written to their peer. Adapt an OpenTelemetry tracer to
+// Tracer to export these spans.
+type Tracer interface {
+	// Start starts a span named name as a child of the current span of
+	// ctx, if any. Returns a copy of ctx carrying the new span (see
+	// ContextWithSpanContext) and the span itself.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// noopTracer is the Tracer used when a Hub has none. It records nothing,
+// but still propagates the current span of its context.
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	spanContext, _ := SpanContextFromContext(ctx)
+	return ctx, noopSpan{spanContext}
+}
+
+// noopSpan is a Span that records nothing.
+type noopSpan struct {
+	spanContext SpanContext
+}
+
+func (s noopSpan) SpanContext() SpanContext  { return s.spanContext }
+func (noopSpan) SetAttribute(string, string) {}
+func (noopSpan) End()                        {}
+
+// tracer returns the Hub's Tracer, or a Tracer recording nothing if it has
+// none.
+func (h *Hub) tracer() Tracer {
+	if h.Tracer == nil {
+		return noopTracer{}
+	}
+	return h.Tracer
+}
+
+// RecordedSpan is a span recorded by MemoryTracer.
+type RecordedSpan struct {
+	// Name is the name of the span.
+	Name string
+	// SpanContext identifies the span.
+	SpanContext SpanContext
+	// Parent identifies the span's parent. Invalid if the span is a root.
+	Parent SpanContext
+	// Attributes are the attributes set on the span.
+	Attributes map[string]string
+	// Start is the time the span started.
+	Start time.Time
+	// End is the time the span ended. Zero if the span has not ended.
+	End time.Time
+}
+
+// MemoryTracer is a Tracer recording spans in memory, for use in tests.
+type MemoryTracer struct {
+	// mu guards spans.
+	mu sync.Mutex
+	// spans are the spans started, in order.
+	spans []*RecordedSpan
+}
+
+// NewMemoryTracer constructs a MemoryTracer with no spans.
+func NewMemoryTracer() *MemoryTracer {
+	return &MemoryTracer{}
+}
+
+func (t *MemoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent, _ := SpanContextFromContext(ctx)
+	spanContext := SpanContext{TraceID: parent.TraceID, Sampled: true, TraceState: parent.TraceState}
+	if !parent.IsValid() {
+		rand.Read(spanContext.TraceID[:])
+	}
+	rand.Read(spanContext.SpanID[:])
+	span := &RecordedSpan{
+		Name:        name,
+		SpanContext: spanContext,
+		Parent:      parent,
+		Attributes:  make(map[string]string),
+		Start:       time.Now(),
+	}
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return ContextWithSpanContext(ctx, spanContext), &memorySpan{tracer: t, span: span}
+}
+
+// Spans returns copies of the spans started, in the order they started.
+func (t *MemoryTracer) Spans() []RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := make([]RecordedSpan, len(t.spans))
+	for i, span := range t.spans {
+		spans[i] = *span
+		spans[i].Attributes = make(map[string]string, len(span.Attributes))
+		for key, value := range span.Attributes {
+			spans[i].Attributes[key] = value
+		}
+	}
+	return spans
+}
+
+// memorySpan is a Span recorded by MemoryTracer.
+type memorySpan struct {
+	tracer *MemoryTracer
+	span   *RecordedSpan
+}
+
+func (s *memorySpan) SpanContext() SpanContext {
+	return s.span.SpanContext
+}
+
+func (s *memorySpan) SetAttribute(key string, value string) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.span.Attributes[key] = value
+}
+
+func (s *memorySpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	if s.span.End.IsZero() {
+		s.span.End = time.Now()
+	}
+}
@@ -0,0 +1,111 @@
+package websocket
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const spanID = "00f067aa0ba902b7"
+	tests := []struct {
+		traceparent string
+		valid       bool
+		sampled     bool
+	}{
+		{"00-" + traceID + "-" + spanID + "-01", true, true},
+		{"00-" + traceID + "-" + spanID + "-00", true, false},
+		{" 00-" + traceID + "-" + spanID + "-03 ", true, true},
+		// Later versions may append fields.
+		{"01-" + traceID + "-" + spanID + "-01-extra", true, true},
+		{"00-" + traceID + "-" + spanID + "-01-extra", false, false},
+		{"ff-" + traceID + "-" + spanID + "-01", false, false},
+		{"0-" + traceID + "-" + spanID + "-01", false, false},
+		{"00-" + traceID[1:] + "-" + spanID + "-01", false, false},
+		{"00-" + traceID + "-" + spanID[1:] + "-01", false, false},
+		{"00-" + traceID + "-" + spanID + "-1", false, false},
+		{"00-" + "x" + traceID[1:] + "-" + spanID + "-01", false, false},
+		{"00-" + traceID + "-" + "x" + spanID[1:] + "-01", false, false},
+		{"00-" + traceID + "-" + spanID + "-0x", false, false},
+		// Only lowercase hex digits are allowed, in every field.
+		{"0a-" + traceID + "-" + spanID + "-01", true, true},
+		{"0A-" + traceID + "-" + spanID + "-01", false, false},
+		{"0x-" + traceID + "-" + spanID + "-01", false, false},
+		{"00-" + strings.ToUpper(traceID) + "-" + spanID + "-01", false, false},
+		{"00-" + traceID + "-" + strings.ToUpper(spanID) + "-01", false, false},
+		{"00-" + traceID + "-" + spanID + "-0A", false, false},
+		{"00-00000000000000000000000000000000-" + spanID + "-01", false, false},
+		{"00-" + traceID + "-0000000000000000-01", false, false},
+		{"", false, false},
+	}
+	for _, test := range tests {
+		spanContext, err := ParseTraceParent(test.traceparent, "vendor=value")
+		if (err == nil) != test.valid {
+			t.Errorf("ParseTraceParent(%q) returned error %v, expected valid %v", test.traceparent, err, test.valid)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		if spanContext.Sampled != test.sampled || spanContext.TraceState != "vendor=value" {
+			t.Errorf("ParseTraceParent(%q) = %+v, expected sampled %v", test.traceparent, spanContext, test.sampled)
+		}
+	}
+
+	// A span context survives a round trip through its header.
+	traceparent := "00-" + traceID + "-" + spanID + "-01"
+	spanContext, err := ParseTraceParent(traceparent, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted := spanContext.TraceParent(); formatted != traceparent {
+		t.Errorf("expected %q, got %q", traceparent, formatted)
+	}
+}
+
+func TestInjectExtractMeta(t *testing.T) {
+	if meta := injectMeta(context.Background()); meta != nil {
+		t.Errorf("expected no meta without a span, got %+v", meta)
+	}
+	for _, meta := range []*EventMeta{nil, {}, {TraceParent: "invalid"}} {
+		if _, ok := SpanContextFromContext(extractMeta(context.Background(), meta)); ok {
+			t.Errorf("expected no span from %+v", meta)
+		}
+	}
+
+	meta := &EventMeta{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", TraceState: "vendor=value"}
+	ctx := extractMeta(context.Background(), meta)
+	if injected := injectMeta(ctx); *injected != *meta {
+		t.Errorf("expected %+v to be propagated, got %+v", meta, injected)
+	}
+}
+
+func TestMemoryTracerParenting(t *testing.T) {
+	tracer := NewMemoryTracer()
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.SetAttribute("key", "value")
+	child.End()
+	_, other := tracer.Start(context.Background(), "other")
+
+	spans := tracer.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	if spans[0].Parent.IsValid() || !spans[0].SpanContext.IsValid() {
+		t.Errorf("expected root to be a valid root span, got %+v", spans[0])
+	}
+	if spans[1].Parent != root.SpanContext() || spans[1].SpanContext.TraceID != root.SpanContext().TraceID {
+		t.Errorf("expected child to continue root's trace, got %+v", spans[1])
+	}
+	if spans[1].Attributes["key"] != "value" || spans[1].End.IsZero() {
+		t.Errorf("expected child to be ended with its attribute, got %+v", spans[1])
+	}
+	if !spans[0].End.IsZero() {
+		t.Error("expected root not to have ended")
+	}
+	if other.SpanContext().TraceID == root.SpanContext().TraceID {
+		t.Error("expected a span without a parent to start a new trace")
+	}
+}
@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// TypedEvent is an event whose data is always a T, so Go code can send and
+// receive it without converting its data by hand. Define typed events with
+// DefineEvent to also generate matching TypeScript bindings with
+// EventTypes.WriteTypeScript.
+type TypedEvent[T any] struct {
+	// Name is the name of the event.
+	Name string
+}
+
+// DefineEvent constructs a TypedEvent named name, registering its data type
+// with types. If types is nil, the event is not registered.
+func DefineEvent[T any](types *EventTypes, name string) TypedEvent[T] {
+	if types != nil {
+		types.Add(name, reflect.TypeFor[T]())
+	}
+	return TypedEvent[T]{Name: name}
+}
+
+// Event encodes data into an Event.
+func (e TypedEvent[T]) Event(data T) (Event, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("encoding %s: %w", e.Name, err)
+	}
+	return Event{Name: e.Name, Data: b}, nil
+}
+
+// Decode decodes the data of event. Returns an error if event has a
+// different name or its data is not a T.
+func (e TypedEvent[T]) Decode(event Event) (T, error) {
+	var data T
+	if event.Name != e.Name {
+		return data, fmt.Errorf("decoding %s: got event %s", e.Name, event.Name)
+	}
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		return data, fmt.Errorf("decoding %s: %w", e.Name, err)
+	}
+	return data, nil
+}
+
+// Broadcast encodes data and broadcasts it from client, like
+// Hub.Broadcast. If client is nil, it is broadcast from no client, like
+// Hub.BroadcastAll.
+func (e TypedEvent[T]) Broadcast(hub *Hub, client Client, data T) error {
+	return e.BroadcastContext(context.Background(), hub, client, data)
+}
+
+// BroadcastContext encodes data and broadcasts it from client, like
+// Hub.BroadcastContext.
+func (e TypedEvent[T]) BroadcastContext(ctx context.Context, hub *Hub, client Client, data T) error {
+	event, err := e.Event(data)
+	if err != nil {
+		return err
+	}
+	hub.BroadcastContext(ctx, client, e.Name, event.Data)
+	return nil
+}
+
+// Handle registers handler with router to receive the decoded data of
+// every event named e.Name. Events whose data is not a T are ignored.
+func (e TypedEvent[T]) Handle(router *Router, handler func(ClientEvent, T)) {
+	router.Handle(e.Name, func(clientEvent ClientEvent) {
+		data, err := e.Decode(clientEvent.Event)
+		if err != nil {
+			return
+		}
+		handler(clientEvent, data)
+	})
+}
+
+// Allow allows clients to send the event to a Hub using registry, rejecting
+// events whose data is not a T.
+func (e TypedEvent[T]) Allow(registry *EventRegistry) {
+	registry.Allow(e.Name, func(data json.RawMessage) error {
+		var decoded T
+		return json.Unmarshal(data, &decoded)
+	})
+}
+
+// EventTypes maps event names to the Go types of their data. It is the
+// source of the TypeScript bindings written by WriteTypeScript. EventTypes
+// is safe for concurrent use.
+type EventTypes struct {
+	// mu guards events.
+	mu sync.Mutex
+	// events are the registered events, in the order they were added.
+	events []eventType
+}
+
+// eventType is an event name and the type of its data.
+type eventType struct {
+	name string
+	data reflect.Type
+}
+
+// NewEventTypes constructs an EventTypes with no events.
+func NewEventTypes() *EventTypes {
+	return &EventTypes{}
+}
+
+// Add registers data as the type of the data of events named name,
+// replacing any type previously registered for name.
+func (t *EventTypes) Add(name string, data reflect.Type) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.events {
+		if t.events[i].name == name {
+			t.events[i].data = data
+			return
+		}
+	}
+	t.events = append(t.events, eventType{name: name, data: data})
+}
+
+// all returns the registered events in the order they were added.
+func (t *EventTypes) all() []eventType {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]eventType(nil), t.events...)
+}
@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventTypesAdd(t *testing.T) {
+	types := NewEventTypes()
+	DefineEvent[int](types, "a")
+	DefineEvent[string](types, "b")
+	DefineEvent[bool](types, "a")
+	events := types.all()
+	if len(events) != 2 || events[0].name != "a" || events[0].data != reflect.TypeFor[bool]() || events[1].name != "b" {
+		t.Errorf("unexpected events %v", events)
+	}
+}
+
+func TestTypedEvent(t *testing.T) {
+	message := TypedEvent[tsUser]{Name: "user"}
+	event, err := message.Event(tsUser{Name: "cooper"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(event.Data) != `{"name":"cooper"}` {
+		t.Errorf("unexpected data %s", event.Data)
+	}
+	user, err := message.Decode(event)
+	if err != nil || user.Name != "cooper" {
+		t.Errorf("Decode returned %v, %v", user, err)
+	}
+	if _, err := message.Decode(Event{Name: "other", Data: event.Data}); err == nil {
+		t.Error("expected an error decoding an event with a different name")
+	}
+	if _, err := message.Decode(Event{Name: "user", Data: json.RawMessage(`[]`)}); err == nil {
+		t.Error("expected an error decoding data of the wrong type")
+	}
+
+	registry := NewEventRegistry()
+	message.Allow(registry)
+	if err := registry.Validate(event); err != nil {
+		t.Errorf("expected event to be allowed, got %v", err)
+	}
+	if err := registry.Validate(Event{Name: "user", Data: json.RawMessage(`"cooper"`)}); err == nil {
+		t.Error("expected data of the wrong type to be rejected")
+	}
+}
+
+func TestTypedEventBroadcast(t *testing.T) {
+	message := TypedEvent[tsUser]{Name: "user"}
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Close()
+
+	router := NewRouter()
+	received := make(chan tsUser, 1)
+	message.Handle(router, func(clientEvent ClientEvent, user tsUser) {
+		received <- user
+	})
+	go router.Run()
+	hub.Register(router, ClientRegistrationOptions{})
+
+	hub.BroadcastAll("user", json.RawMessage(`"not a user"`))
+	if err := message.Broadcast(hub, nil, tsUser{Name: "cooper"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case user := <-received:
+		if user.Name != "cooper" {
+			t.Errorf("expected cooper, got %q", user.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not called")
+	}
+}
@@ -0,0 +1,440 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// TypeScriptOptions configure the module written by
+// EventTypes.WriteTypeScript.
+type TypeScriptOptions struct {
+	// SocketModule is the import path of the module served by
+	// ServeSocketModule, relative to the generated module. Defaults to
+	// "./socket.mjs".
+	SocketModule string
+}
+
+// WriteTypeScript writes a TypeScript module of bindings for the registered
+// events to w. The module declares an interface for every struct type of
+// event data, an Events interface mapping each event name to the type of
+// its data, and functions sending and receiving each event with its data
+// typed:
+//
+//	import { onChatMessage, sendChatMessage } from "./events";
+//	sendChatMessage(socket, { text: "hello" });
+//
+// Types are translated as encoding/json encodes them: field names and
+// omitempty follow json tags, pointers, slices and maps may be null,
+// []byte and encoding.TextMarshalers are strings, and other json.Marshalers
+// are unknown. Returns an error if a type cannot be encoded as JSON, two
+// types translate to the same name, two events translate to the same
+// function names, or an event translates to the name of the generic
+// onEvent function.
+func (t *EventTypes) WriteTypeScript(w io.Writer, options TypeScriptOptions) error {
+	if options.SocketModule == "" {
+		options.SocketModule = "./socket.mjs"
+	}
+	events := t.all()
+	emitter := newTSEmitter()
+	dataTypes := make([]string, len(events))
+	functionNames := make(map[string]string)
+	for i, event := range events {
+		dataType, err := emitter.typeOf(event.data)
+		if err != nil {
+			return fmt.Errorf("data of %s: %w", event.name, err)
+		}
+		dataTypes[i] = dataType
+		suffix := tsFunctionSuffix(event.name)
+		if suffix == "" {
+			return fmt.Errorf("event name %q has no letters or digits to name its functions after", event.name)
+		}
+		if suffix == tsReservedSuffix {
+			return fmt.Errorf("event name %q translates to functions named %s, which are reserved for the generic onEvent", event.name, suffix)
+		}
+		if other, ok := functionNames[suffix]; ok {
+			return fmt.Errorf("events %s and %s both translate to functions named %s", other, event.name, suffix)
+		}
+		functionNames[suffix] = event.name
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "// Code generated by websocket.EventTypes.WriteTypeScript; DO NOT EDIT.\n\n")
+	fmt.Fprintf(out, "import { Socket } from %s;\n", strconv.Quote(options.SocketModule))
+	for _, declaration := range emitter.declarations {
+		fmt.Fprintf(out, "\n%s\n", declaration)
+	}
+
+	fmt.Fprintf(out, "\n/** Events maps the name of each event to the type of its data. */\n")
+	fmt.Fprintf(out, "export interface Events {\n")
+	for i, event := range events {
+		fmt.Fprintf(out, "    %s: %s;\n", strconv.Quote(event.name), dataTypes[i])
+	}
+	fmt.Fprintf(out, "}\n")
+
+	fmt.Fprintf(out, "\n/** Sends the event named name, whose data must match Events. */\n")
+	fmt.Fprintf(out, "export function send<K extends keyof Events>(socket: Socket, name: K, data: Events[K]): void {\n")
+	fmt.Fprintf(out, "    socket.send<Events[K]>(name, data);\n")
+	fmt.Fprintf(out, "}\n")
+	fmt.Fprintf(out, "\n/** Calls callback with the data of every event named name. */\n")
+	fmt.Fprintf(out, "export function onEvent<K extends keyof Events>(socket: Socket, name: K, callback: (socket: Socket, data: Events[K]) => void): void {\n")
+	fmt.Fprintf(out, "    socket.onEvent<Events[K]>(name, callback);\n")
+	fmt.Fprintf(out, "}\n")
+
+	for i, event := range events {
+		suffix, name := tsFunctionSuffix(event.name), strconv.Quote(event.name)
+		fmt.Fprintf(out, "\n/** Sends a %s event. */\n", event.name)
+		fmt.Fprintf(out, "export function send%s(socket: Socket, data: %s): void {\n", suffix, dataTypes[i])
+		fmt.Fprintf(out, "    send(socket, %s, data);\n", name)
+		fmt.Fprintf(out, "}\n")
+		fmt.Fprintf(out, "\n/** Calls callback with the data of every %s event. */\n", event.name)
+		fmt.Fprintf(out, "export function on%s(socket: Socket, callback: (socket: Socket, data: %s) => void): void {\n", suffix, dataTypes[i])
+		fmt.Fprintf(out, "    onEvent(socket, %s, callback);\n", name)
+		fmt.Fprintf(out, "}\n")
+	}
+	return out.Flush()
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// tsEmitter translates Go types into TypeScript types, declaring a named
+// TypeScript type for every named Go type it translates.
+type tsEmitter struct {
+	// declarations are the declared TypeScript types, in the order they
+	// were first referenced.
+	declarations []string
+	// names are the TypeScript names of the declared Go types.
+	names map[reflect.Type]string
+	// declared are the Go types declared under each TypeScript name.
+	declared map[string]reflect.Type
+}
+
+func newTSEmitter() *tsEmitter {
+	return &tsEmitter{
+		names:    make(map[reflect.Type]string),
+		declared: make(map[string]reflect.Type),
+	}
+}
+
+// implements returns true if t or a pointer to t implements iface.
+func implements(t reflect.Type, iface reflect.Type) bool {
+	return t.Implements(iface) || (t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(iface))
+}
+
+// typeOf returns the TypeScript type of the JSON encoding of t.
+func (e *tsEmitter) typeOf(t reflect.Type) (string, error) {
+	switch {
+	case t == timeType:
+		return "string", nil
+	case t == rawMessageType:
+		return "unknown", nil
+	case t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface && implements(t, jsonMarshalerType):
+		return "unknown", nil
+	case t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface && implements(t, textMarshalerType):
+		return "string", nil
+	}
+	if name, ok := e.names[t]; ok {
+		return name, nil
+	}
+	if t.Name() != "" && t.PkgPath() != "" {
+		return e.declare(t)
+	}
+	return e.literal(t)
+}
+
+// literal returns the TypeScript type of t without referring to t by name.
+func (e *tsEmitter) literal(t reflect.Type) (string, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "number", nil
+	case reflect.String:
+		return "string", nil
+	case reflect.Interface:
+		return "unknown", nil
+	case reflect.Pointer:
+		elem, err := e.typeOf(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return nullable(elem), nil
+	case reflect.Slice:
+		// encoding/json encodes []byte as a base64 string.
+		if t.Elem().Kind() == reflect.Uint8 && !implements(t.Elem(), jsonMarshalerType) && !implements(t.Elem(), textMarshalerType) {
+			return "string", nil
+		}
+		elem, err := e.typeOf(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return nullable(arrayOf(elem)), nil
+	case reflect.Array:
+		elem, err := e.typeOf(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return arrayOf(elem), nil
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !implements(t.Key(), textMarshalerType) {
+				return "", fmt.Errorf("map keys of type %v cannot be encoded as JSON", t.Key())
+			}
+		}
+		elem, err := e.typeOf(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return nullable("{ [key: string]: " + elem + " }"), nil
+	case reflect.Struct:
+		fields, err := e.fields(t)
+		if err != nil {
+			return "", err
+		}
+		if len(fields) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(fields, " ") + " }", nil
+	}
+	return "", fmt.Errorf("values of type %v cannot be encoded as JSON", t)
+}
+
+// declare declares a TypeScript type named after the named Go type t,
+// returning its name.
+func (e *tsEmitter) declare(t reflect.Type) (string, error) {
+	name := tsTypeName(t)
+	if name == "Events" || name == "Socket" {
+		return "", fmt.Errorf("type %v translates to %s, which the generated module already declares", t, name)
+	}
+	if other, ok := e.declared[name]; ok {
+		return "", fmt.Errorf("types %v and %v both translate to TypeScript type %s", other, t, name)
+	}
+	// Name t before translating it, so recursive references to it refer
+	// to the declaration.
+	e.names[t] = name
+	e.declared[name] = t
+	index := len(e.declarations)
+	e.declarations = append(e.declarations, "")
+
+	if t.Kind() != reflect.Struct {
+		literal, err := e.literal(t)
+		if err != nil {
+			return "", err
+		}
+		e.declarations[index] = fmt.Sprintf("export type %s = %s;", name, literal)
+		return name, nil
+	}
+	fields, err := e.fields(t)
+	if err != nil {
+		return "", err
+	}
+	var declaration strings.Builder
+	fmt.Fprintf(&declaration, "export interface %s {\n", name)
+	for _, field := range fields {
+		fmt.Fprintf(&declaration, "    %s\n", field)
+	}
+	declaration.WriteString("}")
+	e.declarations[index] = declaration.String()
+	return name, nil
+}
+
+// jsonField is a field of a struct's JSON encoding.
+type jsonField struct {
+	name     string
+	field    reflect.StructField
+	depth    int
+	tagged   bool
+	optional bool
+	quoted   bool
+}
+
+// fields returns the TypeScript property declarations of the JSON encoding
+// of the struct type t.
+func (e *tsEmitter) fields(t reflect.Type) ([]string, error) {
+	var properties []string
+	for _, field := range jsonFields(t, 0, nil) {
+		fieldType, err := e.typeOf(field.field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.field.Name, err)
+		}
+		if field.quoted {
+			switch field.field.Type.Kind() {
+			case reflect.Bool, reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+				reflect.Float32, reflect.Float64:
+				fieldType = "string"
+			}
+		}
+		optional := ""
+		if field.optional {
+			optional = "?"
+		}
+		properties = append(properties, fmt.Sprintf("%s%s: %s;", tsPropertyName(field.name), optional, fieldType))
+	}
+	return properties, nil
+}
+
+// jsonFields returns the fields of the JSON encoding of the struct type t,
+// following the rules of encoding/json for embedded structs: the
+// shallowest field with a name wins, a tagged field wins a tie, and
+// otherwise tied fields are omitted. visited holds the embedded types
+// already being expanded, to stop at cycles.
+func jsonFields(t reflect.Type, depth int, visited map[reflect.Type]bool) []jsonField {
+	if visited[t] {
+		return nil
+	}
+	expanding := map[reflect.Type]bool{t: true}
+	for expanded := range visited {
+		expanding[expanded] = true
+	}
+	var candidates []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for _, promoted := range jsonFields(embedded, depth+1, expanding) {
+					if field.Type.Kind() == reflect.Pointer {
+						promoted.optional = true
+					}
+					candidates = append(candidates, promoted)
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		jsonName := name
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+		candidates = append(candidates, jsonField{
+			name:     jsonName,
+			field:    field,
+			depth:    depth,
+			tagged:   name != "",
+			optional: hasOption(options, "omitempty") || hasOption(options, "omitzero"),
+			quoted:   hasOption(options, "string"),
+		})
+	}
+
+	var fields []jsonField
+	for i, candidate := range candidates {
+		dominant, visible := true, true
+		for j, other := range candidates {
+			if i == j || other.name != candidate.name {
+				continue
+			}
+			if other.depth < candidate.depth || (other.depth == candidate.depth && other.tagged && !candidate.tagged) {
+				dominant = false
+			} else if other.depth == candidate.depth && other.tagged == candidate.tagged {
+				visible = false
+			}
+		}
+		if dominant && visible {
+			fields = append(fields, candidate)
+		}
+	}
+	return fields
+}
+
+// hasOption returns true if the comma-separated json tag options include
+// option.
+func hasOption(options string, option string) bool {
+	for _, candidate := range strings.Split(options, ",") {
+		if candidate == option {
+			return true
+		}
+	}
+	return false
+}
+
+// nullable returns the union of tsType and null.
+func nullable(tsType string) string {
+	if strings.HasSuffix(tsType, " | null") {
+		return tsType
+	}
+	return tsType + " | null"
+}
+
+// arrayOf returns the TypeScript array type of elements of tsType.
+func arrayOf(tsType string) string {
+	if strings.Contains(tsType, " | ") {
+		return "(" + tsType + ")[]"
+	}
+	return tsType + "[]"
+}
+
+// typePackagePath matches the package paths qualifying the type arguments
+// in the names of instantiated generic types.
+var typePackagePath = regexp.MustCompile(`[^\[\],*]*\.`)
+
+// tsTypeName returns the TypeScript name of the named Go type t. The type
+// arguments of generic types are appended to their name, so Page[User] is
+// named PageUser.
+func tsTypeName(t reflect.Type) string {
+	name := t.Name()
+	base, arguments, generic := strings.Cut(name, "[")
+	if !generic {
+		return name
+	}
+	return base + tsFunctionSuffix(typePackagePath.ReplaceAllString(arguments, ""))
+}
+
+// tsReservedSuffix is the function suffix no event may translate to, since
+// on plus it names the generic onEvent function.
+const tsReservedSuffix = "Event"
+
+// tsFunctionSuffix returns the suffix of the names of the functions sending
+// and receiving an event named eventName, which is its runs of letters and
+// digits, capitalized and joined.
+func tsFunctionSuffix(eventName string) string {
+	var suffix strings.Builder
+	for _, word := range strings.FieldsFunc(eventName, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		first := []rune(word)[0]
+		suffix.WriteRune(unicode.ToUpper(first))
+		suffix.WriteString(word[len(string(first)):])
+	}
+	return suffix.String()
+}
+
+// tsIdentifier matches property names that need not be quoted.
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsPropertyName returns name as a TypeScript property name, quoting it if
+// necessary.
+func tsPropertyName(name string) string {
+	if tsIdentifier.MatchString(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
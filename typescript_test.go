@@ -0,0 +1,148 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tsUser struct {
+	Name    string   `json:"name"`
+	Friends []tsUser `json:"friends,omitempty"`
+}
+
+type tsColor string
+
+type tsBase struct {
+	ID      int64 `json:"id,string"`
+	Created time.Time
+}
+
+type tsMessage struct {
+	tsBase
+	Text     string           `json:"text"`
+	Author   *tsUser          `json:"author"`
+	Color    tsColor          `json:"color,omitempty"`
+	Counts   map[string]int   `json:"counts"`
+	Raw      json.RawMessage  `json:"raw"`
+	Address  net.IP           `json:"address"`
+	Blob     []byte           `json:"blob"`
+	Point    [2]float64       `json:"point"`
+	Optional []*string        `json:"optional"`
+	Hidden   string           `json:"-"`
+	Dash     bool             `json:"-,"`
+	Inline   struct{ A bool } `json:"inline"`
+	Extra    map[string]any   `json:"extra-data"`
+	private  int
+}
+
+func TestWriteTypeScript(t *testing.T) {
+	types := NewEventTypes()
+	DefineEvent[tsMessage](types, "chat.message")
+	DefineEvent[[]string](types, SubscribeEventName)
+	DefineEvent[ErrorEventData](types, ErrorEventName)
+
+	var out strings.Builder
+	if err := types.WriteTypeScript(&out, TypeScriptOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `// Code generated by websocket.EventTypes.WriteTypeScript; DO NOT EDIT.
+
+import { Socket } from "./socket.mjs";
+
+export interface tsMessage {
+    id: string;
+    Created: string;
+    text: string;
+    author: tsUser | null;
+    color?: tsColor;
+    counts: { [key: string]: number } | null;
+    raw: unknown;
+    address: string;
+    blob: string;
+    point: number[];
+    optional: (string | null)[] | null;
+    "-": boolean;
+    inline: { A: boolean; };
+    "extra-data": { [key: string]: unknown } | null;
+}
+
+export interface tsUser {
+    name: string;
+    friends?: tsUser[] | null;
+}
+
+export type tsColor = string;
+
+export interface ErrorEventData {
+    code: string;
+    message: string;
+    event?: string;
+    details?: string[] | null;
+}
+
+/** Events maps the name of each event to the type of its data. */
+export interface Events {
+    "chat.message": tsMessage;
+    "$subscribe": string[] | null;
+    "$error": ErrorEventData;
+}
+
+/** Sends the event named name, whose data must match Events. */
+export function send<K extends keyof Events>(socket: Socket, name: K, data: Events[K]): void {
+    socket.send<Events[K]>(name, data);
+}
+
+/** Calls callback with the data of every event named name. */
+export function onEvent<K extends keyof Events>(socket: Socket, name: K, callback: (socket: Socket, data: Events[K]) => void): void {
+    socket.onEvent<Events[K]>(name, callback);
+}
+`
+	if !strings.HasPrefix(out.String(), expected) {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+	for _, function := range []string{
+		"export function sendChatMessage(socket: Socket, data: tsMessage): void {\n    send(socket, \"chat.message\", data);\n}",
+		"export function onSubscribe(socket: Socket, callback: (socket: Socket, data: string[] | null) => void): void {",
+		"export function sendError(socket: Socket, data: ErrorEventData): void {",
+	} {
+		if !strings.Contains(out.String(), function) {
+			t.Errorf("expected output to contain %q", function)
+		}
+	}
+}
+
+func TestWriteTypeScriptErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		types func(*EventTypes)
+		err   string
+	}{
+		{"unencodable", func(types *EventTypes) {
+			DefineEvent[chan int](types, "chan")
+		}, "cannot be encoded"},
+		{"map keys", func(types *EventTypes) {
+			DefineEvent[map[[2]int]string](types, "map")
+		}, "map keys"},
+		{"function names", func(types *EventTypes) {
+			DefineEvent[string](types, "chat.message")
+			DefineEvent[string](types, "chat_message")
+		}, "both translate to functions named ChatMessage"},
+		{"reserved", func(types *EventTypes) {
+			DefineEvent[string](types, "event")
+		}, "reserved for the generic onEvent"},
+		{"unnamed", func(types *EventTypes) {
+			DefineEvent[string](types, "$")
+		}, "no letters or digits"},
+	}
+	for _, test := range tests {
+		types := NewEventTypes()
+		test.types(types)
+		err := types.WriteTypeScript(&strings.Builder{}, TypeScriptOptions{})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.name, test.err, err)
+		}
+	}
+}
@@ -1,32 +1,84 @@
 package websocket
 
 import (
+	"log/slog"
 	"net/http"
 
 	"github.com/gorilla/websocket"
 )
 
-// Generate socket.js.go.
-//go:generate tsc --lib dom,es2015 test/socket.ts
-//go:generate go run generate/generate.go
-
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 }
 
+// WebsocketOptions configure a WebsocketClient created by
+// ServeWebsocketWithOptions.
+type WebsocketOptions struct {
+	// OnClose is a function to run when the client is disconnected from the Hub.
+	OnClose func(*Hub)
+	// RateLimit limits the rate at which the client may send events to the
+	// Hub. If nil, the client is not rate limited.
+	RateLimit *RateLimitOptions
+	// Filter decides whether the client receives an event. See
+	// ClientRegistrationOptions.Filter.
+	Filter func(ClientEvent) bool
+	// Subscriptions restrict which events the client receives by name. See
+	// ClientRegistrationOptions.Subscriptions.
+	Subscriptions []string
+	// Metadata is arbitrary information describing the client, reported by
+	// Hub.Clients.
+	Metadata map[string]string
+	// Logger receives the client's log messages. If nil, the Hub's Logger
+	// is used.
+	Logger *slog.Logger
+	// Ack configures the retransmission of events the client's peer sends
+	// with an ID, which the Hub broadcasts with BroadcastAcked.
+	Ack AckOptions
+}
+
 // ServeWebsocket upgrades an HTTP request to a websocket connection.
 //   hub is the Hub to register the client with.
 //   w is the ResponseWriter associated with the request.
 //   req is the Request.
 //   onClose is a function to run when the client is disconnected from the Hub.
 func ServeWebsocket(hub *Hub, w http.ResponseWriter, req *http.Request, onClose func(*Hub)) (*WebsocketClient, error) {
+	return ServeWebsocketWithOptions(hub, w, req, WebsocketOptions{OnClose: onClose})
+}
+
+// ServeWebsocketWithOptions upgrades an HTTP request to a websocket connection
+// configured by options.
+//   hub is the Hub to register the client with.
+//   w is the ResponseWriter associated with the request.
+//   req is the Request.
+//   options configure the client.
+func ServeWebsocketWithOptions(hub *Hub, w http.ResponseWriter, req *http.Request, options WebsocketOptions) (*WebsocketClient, error) {
 	conn, err := upgrader.Upgrade(w, req, nil)
 	if err != nil {
 		return nil, err
 	}
-	client := WebsocketClient{hub: hub, conn: conn, send: make(chan ClientEvent, 256), eventsToIgnore: make(map[string]bool)}
-	client.hub.Register(&client, ClientRegistrationOptions{false, onClose})
+	client := WebsocketClient{
+		id:      newClientID(),
+		hub:     hub,
+		conn:    conn,
+		send:    make(chan ClientEvent, 256),
+		replies: make(chan Event, repliesBufferSize),
+	}
+	logger := hub.logger()
+	if options.Logger != nil {
+		logger = options.Logger.With("hub", hub.Key)
+	}
+	client.logger = logger.With("client", client.id, "remote_addr", conn.RemoteAddr().String())
+	if options.RateLimit != nil {
+		client.rateLimiter = newRateLimiter(*options.RateLimit, hub.clock().Now())
+	}
+	client.ackOptions = options.Ack
+	client.hub.Register(&client, ClientRegistrationOptions{
+		OnClose:       options.OnClose,
+		Filter:        options.Filter,
+		Subscriptions: options.Subscriptions,
+		Metadata:      options.Metadata,
+	})
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
@@ -38,7 +90,9 @@ func ServeWebsocket(hub *Hub, w http.ResponseWriter, req *http.Request, onClose
 // ServeSocketJs serves a Javascript file containing a Socket class
 // which abstracts a native WebSocket element. It allows the sending
 // of named events instead of raw strings, conforming to Hub's API.
+//
+// The file is served with an ETag so browsers can revalidate their cached
+// copy, and compressed with gzip for browsers that accept it.
 func ServeSocketJs(w http.ResponseWriter, req *http.Request) error {
-	_, err := w.Write([]byte(socketJsContents))
-	return err
+	return socketJsAsset.serve(w, req)
 }
@@ -2,9 +2,15 @@ package websocket
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 )
@@ -19,10 +25,18 @@ const (
 	// Send pings to peer with this period. Must be less than pongWait.
 	pingPeriod = (pongWait * 9) / 10
 
-	// Maximum message size allowed from peer.
-	maxMessageSize = 512
+	// Number of replies sent directly to the peer that may be queued.
+	repliesBufferSize = 16
+
+	// Maximum size in bytes of the reason in a close frame, whose payload
+	// is limited to 125 bytes including the 2 byte close code.
+	maxCloseReasonSize = 123
 )
 
+// MaxMessageSize is the largest message in bytes a WebsocketClient reads
+// from its peer. Larger messages close the connection.
+const MaxMessageSize = 512
+
 var (
 	newline = []byte{'\n'}
 	space   = []byte{' '}
@@ -30,6 +44,9 @@ var (
 
 // WebsocketClient is a Client sending and receiving messages from an HTTP Websocket.
 type WebsocketClient struct {
+	// id uniquely identifies this client.
+	id string
+
 	// hub is the Hub this client is registered with.
 	hub *Hub
 
@@ -39,10 +56,62 @@ type WebsocketClient struct {
 	// Buffered channel of outbound messages.
 	send chan ClientEvent
 
-	// The names of events this client should not send.
+	// Buffered channel of events sent directly to the peer, bypassing the
+	// hub. Unlike send, replies is never closed, so readPump may write to
+	// it after the hub has closed the client.
+	replies chan Event
+
+	// logger receives this client's log messages.
+	logger *slog.Logger
+
+	// closeFrame is the close frame sent to the peer when the hub closes
+	// this client. nil to send an empty close frame.
+	closeFrame atomic.Pointer[[]byte]
+
+	// rateLimiter limits the events the peer may send to the hub. nil if
+	// the client is not rate limited.
+	rateLimiter *rateLimiter
+
+	// ackOptions configure the retransmission of events the peer sends
+	// with an ID.
+	ackOptions AckOptions
+
+	// ignoreLock guards eventsToIgnore.
+	ignoreLock sync.RWMutex
+	// The names of events this client should not send. nil until
+	// IgnoreEvents is first called.
 	eventsToIgnore map[string]bool
 }
 
+// ID returns the randomly generated ID of w.
+func (w *WebsocketClient) ID() string {
+	return w.id
+}
+
+// RemoteAddr returns the network address of w's peer.
+func (w *WebsocketClient) RemoteAddr() net.Addr {
+	return w.conn.RemoteAddr()
+}
+
+// setCloseReason sets the close code and reason sent to w's peer when the
+// hub closes w. reason is truncated to fit in a close frame.
+func (w *WebsocketClient) setCloseReason(code int, reason string) {
+	closeFrame := websocket.FormatCloseMessage(code, truncateUTF8(reason, maxCloseReasonSize))
+	w.closeFrame.Store(&closeFrame)
+}
+
+// truncateUTF8 returns the longest prefix of s of at most n bytes that does
+// not split a UTF-8 sequence.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
 func (w *WebsocketClient) Send() chan<- ClientEvent {
 	return w.send
 }
@@ -52,13 +121,183 @@ func (w *WebsocketClient) Close() {
 }
 
 // IgnoreEvents causes w to silently refuse to send any event with the given
-// event names.
+// event names. Names are matched exactly, not as patterns, and ignored
+// events still take up space in w's buffer; use Hub.Unsubscribe to stop the
+// hub queueing events for w at all. Safe to call from any goroutine.
 func (w *WebsocketClient) IgnoreEvents(eventNames ...string) {
+	w.ignoreLock.Lock()
+	defer w.ignoreLock.Unlock()
+	if w.eventsToIgnore == nil {
+		w.eventsToIgnore = make(map[string]bool)
+	}
 	for _, eventName := range eventNames {
 		w.eventsToIgnore[eventName] = true
 	}
 }
 
+// ignores returns true if w should not send events named eventName.
+func (w *WebsocketClient) ignores(eventName string) bool {
+	w.ignoreLock.RLock()
+	defer w.ignoreLock.RUnlock()
+	return w.eventsToIgnore[eventName]
+}
+
+// ThrottledEvents returns the number of events w's peer has sent that were
+// throttled by its rate limit, keyed by event name.
+func (w *WebsocketClient) ThrottledEvents() map[string]uint64 {
+	if w.rateLimiter == nil {
+		return map[string]uint64{}
+	}
+	return w.rateLimiter.throttledEvents()
+}
+
+// reply queues an event to be sent to w's peer without going through the
+// hub. Replies are dropped if the queue is full.
+func (w *WebsocketClient) reply(event Event) {
+	select {
+	case w.replies <- event:
+	default:
+	}
+}
+
+// throttle applies w's rate limit to an event. throttled is true if the
+// event should be discarded instead of sent to the hub, and disconnect is
+// true if the connection should also be closed.
+func (w *WebsocketClient) throttle(eventName string) (throttled bool, disconnect bool) {
+	if w.rateLimiter == nil || w.rateLimiter.allow(eventName, w.hub.clock().Now()) {
+		return false, false
+	}
+	w.hub.metrics().EventThrottled(w.hub.Key)
+	w.logger.Debug("throttled event", "event", eventName, "policy", w.rateLimiter.policy)
+	switch w.rateLimiter.policy {
+	case RateLimitError:
+		w.reply(newErrorEvent(ErrorEventData{
+			Code:    ErrorCodeRateLimited,
+			Message: "rate limit exceeded",
+			Event:   eventName,
+		}))
+	case RateLimitDisconnect:
+		closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "rate limit exceeded")
+		w.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(writeWait))
+		return true, true
+	}
+	return true, false
+}
+
+// changeSubscriptions applies a SubscribeEventName or UnsubscribeEventName
+// event sent by w's peer.
+func (w *WebsocketClient) changeSubscriptions(event Event) {
+	patterns, err := decodePatterns(event.Data)
+	if err != nil {
+		w.reply(newErrorEvent(ErrorEventData{
+			Code:    ErrorCodeInvalidPayload,
+			Message: err.Error(),
+			Event:   event.Name,
+		}))
+		return
+	}
+	change := subscriptionChange{client: w, patterns: patterns, subscribe: event.Name == SubscribeEventName}
+	if err := w.hub.changePeerSubscriptions(change); err != nil {
+		w.reply(newErrorEvent(ErrorEventData{
+			Code:    ErrorCodeSubscriptionLimit,
+			Message: err.Error(),
+			Event:   event.Name,
+		}))
+	}
+}
+
+// acknowledge applies an AckEventName event sent by w's peer.
+func (w *WebsocketClient) acknowledge(event Event) {
+	id, err := decodeAck(event.Data)
+	if err != nil {
+		w.reply(newErrorEvent(ErrorEventData{
+			Code:    ErrorCodeInvalidPayload,
+			Message: "expected the ID of the acknowledged event",
+			Event:   event.Name,
+		}))
+		return
+	}
+	w.hub.Ack(w, id)
+}
+
+// broadcastAcked broadcasts an event w's peer sent with an ID, replying
+// with an AckEventName event reporting its delivery once it is complete.
+func (w *WebsocketClient) broadcastAcked(ctx context.Context, event Event) {
+	w.hub.BroadcastAcked(ctx, w, event.Name, event.Data, w.ackOptions, func(report DeliveryReport) {
+		report.ID = event.ID
+		b, _ := json.Marshal(report)
+		w.reply(Event{Name: AckEventName, Data: b})
+	})
+}
+
+// closeCode returns the close code of a connection closed with err, or 0
+// if the connection was not closed with a close frame.
+func closeCode(err error) int {
+	if closeErr, ok := err.(*websocket.CloseError); ok {
+		return closeErr.Code
+	}
+	return 0
+}
+
+// handleEvent answers heartbeats, applies acknowledgements, and applies
+// rate limits, subscription changes and validation to an event read from
+// w's peer, broadcasting it if it passes them. Returns true if the
+// connection should be closed.
+func (w *WebsocketClient) handleEvent(event Event) (disconnect bool) {
+	ctx := extractMeta(context.Background(), event.Meta)
+	ctx, span := w.hub.tracer().Start(ctx, "websocket.receive")
+	defer span.End()
+	span.SetAttribute("websocket.hub", w.hub.Key)
+	span.SetAttribute("websocket.client", w.id)
+	span.SetAttribute("websocket.event", event.Name)
+
+	// Heartbeats are not rate limited, so a throttled peer does not think
+	// its connection died. Nor are acknowledgements, so a throttled peer
+	// is not sent events it already received.
+	if event.Name == PingEventName {
+		w.conn.SetReadDeadline(time.Now().Add(pongWait))
+		w.reply(Event{Name: PongEventName, Data: event.Data})
+		return false
+	}
+	if event.Name == AckEventName {
+		w.acknowledge(event)
+		return false
+	}
+	if throttled, disconnect := w.throttle(event.Name); throttled {
+		return disconnect
+	}
+	if event.Name == SubscribeEventName || event.Name == UnsubscribeEventName {
+		w.changeSubscriptions(event)
+		return false
+	}
+	if w.hub.Events != nil {
+		if err := w.hub.Events.Validate(event); err != nil {
+			w.logger.Debug("rejected event", "event", event.Name, "error", err)
+			w.reply(err.(*ValidationError).errorEvent())
+			return false
+		}
+	}
+	if event.ID != "" {
+		w.broadcastAcked(ctx, event)
+		return false
+	}
+	w.hub.BroadcastContext(ctx, w, event.Name, event.Data)
+	return false
+}
+
+// decodeEvent decodes a message read from a peer into an Event. message is
+// untrusted, so decodeEvent must return an error rather than panic for any
+// input. Any JSON object decodes, even one without a name, as events
+// always have.
+func decodeEvent(message []byte) (Event, error) {
+	message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
+	var event Event
+	if err := json.Unmarshal(message, &event); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
 // readPump pumps messages from the websocket connection to the hub.
 //
 // The application runs readPump in a per-connection goroutine. The application
@@ -66,29 +305,78 @@ func (w *WebsocketClient) IgnoreEvents(eventNames ...string) {
 // reads from this goroutine.
 func (w *WebsocketClient) readPump() {
 	defer func() {
-		w.hub.unregister <- w
+		w.hub.Unregister(w)
 		w.conn.Close()
 	}()
-	w.conn.SetReadLimit(maxMessageSize)
+	w.conn.SetReadLimit(MaxMessageSize)
 	w.conn.SetReadDeadline(time.Now().Add(pongWait))
-	w.conn.SetPongHandler(func(string) error { w.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	w.conn.SetPongHandler(func(appData string) error {
+		w.conn.SetReadDeadline(time.Now().Add(pongWait))
+		// writePump sends the time each ping was sent as its payload.
+		if sent, err := strconv.ParseInt(appData, 10, 64); err == nil {
+			w.hub.metrics().PingRTT(w.hub.Key, w.hub.clock().Now().Sub(time.Unix(0, sent)))
+		}
+		return nil
+	})
 	for {
 		_, message, err := w.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure) {
-				log.Printf("error: %v", err)
+				w.logger.Warn("unexpected close", "error", err, "close_code", closeCode(err))
+			} else {
+				w.logger.Debug("connection closed", "close_code", closeCode(err))
 			}
 			break
 		}
-		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
-		var event Event
-		err = json.Unmarshal(message, &event)
+		w.hub.metrics().EventReceived(w.hub.Key, len(message))
+		event, err := decodeEvent(message)
 		if err != nil {
-			log.Printf("error marshalling bytes: %v. Skipping message", err)
+			w.logger.Warn("skipping malformed event", "error", err)
 			continue
 		}
-		w.hub.Broadcast(w, event.Name, event.Data)
+		if disconnect := w.handleEvent(event); disconnect {
+			break
+		}
+	}
+}
+
+// writeEvent writes a single event to the websocket connection. Events that
+// cannot be marshalled are skipped. Returns an error if the connection
+// could not be written to. Must only be called from writePump.
+func (w *WebsocketClient) writeEvent(event Event) error {
+	writer, err := w.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	message, err := json.Marshal(event)
+	if err == nil {
+		writer.Write(message)
+		w.hub.metrics().EventSent(w.hub.Key, len(message))
+	} else {
+		w.logger.Error("skipping event that cannot be marshalled", "event", event.Name, "error", err)
+	}
+	return writer.Close()
+}
+
+// deliverEvent writes an event received from the hub to the websocket
+// connection, recording the delivery as a span. Must only be called from
+// writePump.
+func (w *WebsocketClient) deliverEvent(clientEvent ClientEvent) error {
+	ctx := clientEvent.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := w.hub.tracer().Start(ctx, "websocket.deliver")
+	defer span.End()
+	span.SetAttribute("websocket.hub", w.hub.Key)
+	span.SetAttribute("websocket.client", w.id)
+	span.SetAttribute("websocket.event", clientEvent.Event.Name)
+	event := clientEvent.Event
+	if meta := injectMeta(ctx); meta != nil {
+		// The peer's work continues the delivery span.
+		event.Meta = meta
 	}
+	return w.writeEvent(event)
 }
 
 // writePump pumps messages from the hub to the websocket connection.
@@ -97,7 +385,8 @@ func (w *WebsocketClient) readPump() {
 // application ensures that there is at most one writer to a connection by
 // executing all writes from this goroutine.
 func (w *WebsocketClient) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	clock := w.hub.clock()
+	ticker := clock.NewTicker(pingPeriod)
 	defer func() {
 		ticker.Stop()
 		w.conn.Close()
@@ -105,33 +394,31 @@ func (w *WebsocketClient) writePump() {
 	for {
 		select {
 		case clientEvent, ok := <-w.send:
-			if _, ok := w.eventsToIgnore[clientEvent.Event.Name]; ok {
-				break
-			}
 			w.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// The hub closed the channel.
-				w.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeFrame := []byte{}
+				if reason := w.closeFrame.Load(); reason != nil {
+					closeFrame = *reason
+				}
+				w.conn.WriteMessage(websocket.CloseMessage, closeFrame)
 				return
 			}
-
-			writer, err := w.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
+			if w.ignores(clientEvent.Event.Name) {
+				break
 			}
-			message, err := json.Marshal(clientEvent.Event)
-			if err == nil {
-				writer.Write(message)
-			} else {
-				log.Printf("failed to marshal event: %v. skipping", clientEvent.Event)
+			if err := w.deliverEvent(clientEvent); err != nil {
+				return
 			}
-
-			if err := writer.Close(); err != nil {
+		case event := <-w.replies:
+			w.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := w.writeEvent(event); err != nil {
 				return
 			}
-		case <-ticker.C:
+		case <-ticker.C():
 			w.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := w.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			ping := []byte(strconv.FormatInt(clock.Now().UnixNano(), 10))
+			if err := w.conn.WriteMessage(websocket.PingMessage, ping); err != nil {
 				return
 			}
 		}
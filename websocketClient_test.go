@@ -0,0 +1,90 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	tests := []struct {
+		message string
+		name    string
+		data    string
+		valid   bool
+	}{
+		{`{"name":"chat","data":{"text":"hi"}}`, "chat", `{"text":"hi"}`, true},
+		{"  {\"name\":\"chat\",\n\"data\":[1,\n2]}\n", "chat", `[1, 2]`, true},
+		{`{"name":"chat"}`, "chat", ``, true},
+		{`{"data":1}`, "", "1", true},
+		{`null`, "", "", true},
+		{`{"name":1}`, "", "", false},
+		{`{"name":"chat"`, "", "", false},
+		{``, "", "", false},
+	}
+	for _, test := range tests {
+		event, err := decodeEvent([]byte(test.message))
+		if (err == nil) != test.valid {
+			t.Errorf("decodeEvent(%q) returned error %v, expected valid %v", test.message, err, test.valid)
+			continue
+		}
+		if event.Name != test.name || string(event.Data) != test.data {
+			t.Errorf("decodeEvent(%q) = %q %s, expected %q %s", test.message, event.Name, event.Data, test.name, test.data)
+		}
+	}
+}
+
+func FuzzDecodeEvent(f *testing.F) {
+	f.Add([]byte(`{"name":"chat","data":{"text":"hi"}}`))
+	f.Add([]byte("{\"name\":\"chat\",\n\"data\":\"line\"}"))
+	f.Add([]byte(`{"name":"chat","data":null,"meta":{"traceparent":"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}`))
+	f.Add([]byte(`{"name":"$subscribe","data":["chat.*"]}`))
+	f.Add([]byte(`null`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte{0xff, '{'})
+	f.Fuzz(func(t *testing.T, message []byte) {
+		event, err := decodeEvent(message)
+		if err != nil {
+			return
+		}
+		// An event that decodes survives a round trip through the wire
+		// format, as when the hub sends it on to other peers.
+		encoded, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("decoded event of %q cannot be encoded: %v", message, err)
+		}
+		again, err := decodeEvent(encoded)
+		if err != nil {
+			t.Fatalf("encoded event %q cannot be decoded: %v", encoded, err)
+		}
+		reencoded, err := json.Marshal(again)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("round trip of %q changed %q to %q", message, encoded, reencoded)
+		}
+	})
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		s        string
+		n        int
+		expected string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 5, "hello"},
+		{"hello", 3, "hel"},
+		{"héllo", 2, "h"},
+		{"héllo", 3, "hé"},
+		{"日本", 5, "日"},
+		{"日本", 2, ""},
+		{"", 0, ""},
+	}
+	for _, test := range tests {
+		if truncated := truncateUTF8(test.s, test.n); truncated != test.expected {
+			t.Errorf("truncateUTF8(%q, %d) = %q, expected %q", test.s, test.n, truncated, test.expected)
+		}
+	}
+}
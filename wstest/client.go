@@ -0,0 +1,96 @@
+// Package wstest provides utilities for testing Hubs: an in-memory Client
+// recording the events it receives, and a Server standing up a Hub behind a
+// real websocket endpoint with Go clients to connect to it.
+package wstest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/CooperCorona/websocket"
+)
+
+// Client is an in-memory websocket.Client that records every ClientEvent
+// it receives. Register it with a Hub like any other Client. Client is
+// safe for concurrent use.
+type Client struct {
+	// send is the channel receiving events from the Hub.
+	send chan websocket.ClientEvent
+	// events are the events received so far.
+	events *eventLog[websocket.ClientEvent]
+	// closed is closed once the Hub closes the client and every event has
+	// been recorded.
+	closed chan struct{}
+}
+
+// NewClient constructs a Client.
+func NewClient() *Client {
+	client := &Client{
+		send:   make(chan websocket.ClientEvent, 256),
+		events: newEventLog[websocket.ClientEvent](),
+		closed: make(chan struct{}),
+	}
+	go client.receive()
+	return client
+}
+
+// receive records events until the client is closed.
+func (c *Client) receive() {
+	defer close(c.closed)
+	for clientEvent := range c.send {
+		c.events.append(clientEvent)
+	}
+	c.events.close()
+}
+
+func (c *Client) Send() chan<- websocket.ClientEvent {
+	return c.send
+}
+
+func (c *Client) Close() {
+	close(c.send)
+}
+
+// Events returns every event the client has received so far.
+func (c *Client) Events() []websocket.ClientEvent {
+	return c.events.all()
+}
+
+// ExpectEvent waits up to timeout for the client to receive an event named
+// name, returning it. Each call consumes the events received up to and
+// including the one returned, so successive calls expect events in order.
+// Returns an error wrapping ErrTimeout if no such event arrives in time, or
+// ErrClosed if the client is closed first.
+func (c *Client) ExpectEvent(name string, timeout time.Duration) (websocket.ClientEvent, error) {
+	clientEvent, err := c.events.expect(func(clientEvent websocket.ClientEvent) bool {
+		return clientEvent.Event.Name == name
+	}, timeout)
+	if err != nil {
+		return clientEvent, fmt.Errorf("expecting event %q: %w", name, err)
+	}
+	return clientEvent, nil
+}
+
+// Closed returns a channel that is closed once the Hub has closed the
+// client.
+func (c *Client) Closed() <-chan struct{} {
+	return c.closed
+}
+
+// WaitClosed waits up to timeout for the Hub to close the client. Returns
+// ErrTimeout if it does not.
+func (c *Client) WaitClosed(timeout time.Duration) error {
+	return waitClosed(c.closed, timeout)
+}
+
+// waitClosed waits up to timeout for closed to be closed.
+func waitClosed(closed <-chan struct{}, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-closed:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("waiting to close: %w", ErrTimeout)
+	}
+}
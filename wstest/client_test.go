@@ -0,0 +1,62 @@
+package wstest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CooperCorona/websocket"
+)
+
+func TestClientExpectEvent(t *testing.T) {
+	hub := websocket.NewHub()
+	go hub.Run()
+	defer hub.Close()
+	client := NewClient()
+	hub.Register(client, websocket.ClientRegistrationOptions{})
+
+	hub.BroadcastAll("first", []byte(`1`))
+	hub.BroadcastAll("second", []byte(`2`))
+	hub.BroadcastAll("first", []byte(`3`))
+
+	clientEvent, err := client.ExpectEvent("second", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(clientEvent.Event.Data) != "2" {
+		t.Errorf("expected data 2, got %s", clientEvent.Event.Data)
+	}
+	// The first "first" event was consumed by expecting "second".
+	clientEvent, err = client.ExpectEvent("first", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(clientEvent.Event.Data) != "3" {
+		t.Errorf("expected data 3, got %s", clientEvent.Event.Data)
+	}
+	if len(client.Events()) != 3 {
+		t.Errorf("expected 3 events, got %d", len(client.Events()))
+	}
+}
+
+func TestClientExpectEventTimeout(t *testing.T) {
+	client := NewClient()
+	if _, err := client.ExpectEvent("missing", 10*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+}
+
+func TestClientClosedByHub(t *testing.T) {
+	hub := websocket.NewHub()
+	go hub.Run()
+	client := NewClient()
+	hub.Register(client, websocket.ClientRegistrationOptions{})
+	hub.Close()
+
+	if err := client.WaitClosed(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ExpectEvent("missing", time.Second); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package wstest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/CooperCorona/websocket"
+	gorilla "github.com/gorilla/websocket"
+)
+
+// closeWait is how long Close waits for the server to acknowledge a close
+// frame.
+const closeWait = time.Second
+
+// Conn is a Go websocket client speaking the Hub's {name, data} event
+// protocol, such as one returned by Server.Dial. Conn acknowledges every
+// event sent with an ID, and records retransmissions of an event only
+// once. Conn is safe for concurrent use.
+type Conn struct {
+	// conn is the underlying connection.
+	conn *gorilla.Conn
+	// writeLock serializes writes to conn.
+	writeLock sync.Mutex
+	// events are the events received so far.
+	events *eventLog[websocket.Event]
+	// seen are the IDs of the events received so far. Only accessed from
+	// receive.
+	seen map[string]bool
+	// closed is closed once the connection is closed.
+	closed chan struct{}
+	// err is the error that ended the connection. Only read after closed
+	// is closed.
+	err error
+}
+
+// NewConn wraps an established websocket connection to a Hub, such as one
+// opened with gorilla's Dialer, in a Conn.
+func NewConn(conn *gorilla.Conn) *Conn {
+	return newConn(conn)
+}
+
+func newConn(conn *gorilla.Conn) *Conn {
+	c := &Conn{
+		conn:   conn,
+		events: newEventLog[websocket.Event](),
+		seen:   make(map[string]bool),
+		closed: make(chan struct{}),
+	}
+	go c.receive()
+	return c
+}
+
+// receive records events until the connection is closed.
+func (c *Conn) receive() {
+	defer close(c.closed)
+	defer c.events.close()
+	defer c.conn.Close()
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			c.err = err
+			return
+		}
+		var event websocket.Event
+		if err := json.Unmarshal(message, &event); err != nil {
+			c.err = fmt.Errorf("decoding %q: %w", message, err)
+			return
+		}
+		if event.ID != "" {
+			// Acknowledge retransmissions too, in case an earlier
+			// acknowledgement was lost.
+			c.Send(websocket.AckEventName, event.ID)
+			if c.seen[event.ID] {
+				continue
+			}
+			c.seen[event.ID] = true
+		}
+		c.events.append(event)
+	}
+}
+
+// Send sends an event named name with data marshaled as JSON.
+func (c *Conn) Send(name string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return c.SendEvent(websocket.Event{Name: name, Data: b})
+}
+
+// SendEvent sends event.
+func (c *Conn) SendEvent(event websocket.Event) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.conn.WriteJSON(event)
+}
+
+// Events returns every event the connection has received so far.
+func (c *Conn) Events() []websocket.Event {
+	return c.events.all()
+}
+
+// ExpectEvent waits up to timeout for the connection to receive an event
+// named name, returning it. Each call consumes the events received up to
+// and including the one returned, so successive calls expect events in
+// order. Returns an error wrapping ErrTimeout if no such event arrives in
+// time, or ErrClosed if the connection closes first.
+func (c *Conn) ExpectEvent(name string, timeout time.Duration) (websocket.Event, error) {
+	event, err := c.events.expect(func(event websocket.Event) bool {
+		return event.Name == name
+	}, timeout)
+	if err != nil {
+		return event, fmt.Errorf("expecting event %q: %w", name, err)
+	}
+	return event, nil
+}
+
+// Close sends a normal closure close frame and waits briefly for the
+// server to close the connection.
+func (c *Conn) Close() error {
+	c.writeLock.Lock()
+	err := c.conn.WriteControl(gorilla.CloseMessage, gorilla.FormatCloseMessage(gorilla.CloseNormalClosure, ""), time.Now().Add(closeWait))
+	c.writeLock.Unlock()
+	if err != nil && !errors.Is(err, gorilla.ErrCloseSent) {
+		c.conn.Close()
+		return err
+	}
+	if waitClosed(c.closed, closeWait) != nil {
+		c.conn.Close()
+	}
+	return nil
+}
+
+// Closed returns a channel that is closed once the connection is closed.
+func (c *Conn) Closed() <-chan struct{} {
+	return c.closed
+}
+
+// WaitClosed waits up to timeout for the connection to close, such as
+// when the Hub closes. Returns ErrTimeout if it does not.
+func (c *Conn) WaitClosed(timeout time.Duration) error {
+	return waitClosed(c.closed, timeout)
+}
+
+// CloseError returns the close frame the server sent, or nil if the
+// connection is open or ended without one.
+func (c *Conn) CloseError() *gorilla.CloseError {
+	select {
+	case <-c.closed:
+	default:
+		return nil
+	}
+	var closeErr *gorilla.CloseError
+	if errors.As(c.err, &closeErr) {
+		return closeErr
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package wstest
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTimeout is returned when an expected event does not arrive in time.
+	ErrTimeout = errors.New("timed out")
+	// ErrClosed is returned when a client closes before an expected event
+	// arrives.
+	ErrClosed = errors.New("closed")
+)
+
+// eventLog records events as they arrive, and lets tests wait for them in
+// order.
+type eventLog[T any] struct {
+	// mu guards all fields below.
+	mu sync.Mutex
+	// events are every event received, in order.
+	events []T
+	// next is the index of the first event not yet consumed by expect.
+	next int
+	// changed is closed, and replaced, whenever an event is appended or the
+	// log is closed.
+	changed chan struct{}
+	// closed is true once no more events will be appended.
+	closed bool
+}
+
+func newEventLog[T any]() *eventLog[T] {
+	return &eventLog[T]{changed: make(chan struct{})}
+}
+
+// append records event.
+func (l *eventLog[T]) append(event T) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+	l.signal()
+}
+
+// close marks the log as complete.
+func (l *eventLog[T]) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	l.signal()
+}
+
+// signal wakes goroutines waiting in expect. l.mu must be held.
+func (l *eventLog[T]) signal() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// all returns every event received.
+func (l *eventLog[T]) all() []T {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]T(nil), l.events...)
+}
+
+// expect waits up to timeout for the next unconsumed event matching match,
+// consuming it and every event before it. Returns ErrClosed if the log is
+// closed first, or ErrTimeout if the timeout expires.
+func (l *eventLog[T]) expect(match func(T) bool, timeout time.Duration) (T, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		l.mu.Lock()
+		for i := l.next; i < len(l.events); i++ {
+			if match(l.events[i]) {
+				l.next = i + 1
+				event := l.events[i]
+				l.mu.Unlock()
+				return event, nil
+			}
+		}
+		closed := l.closed
+		changed := l.changed
+		l.mu.Unlock()
+
+		var zero T
+		if closed {
+			return zero, ErrClosed
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return zero, ErrTimeout
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package wstest
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CooperCorona/websocket"
+	gorilla "github.com/gorilla/websocket"
+)
+
+// dialTimeout is how long Dial waits for the Hub to register a connection.
+const dialTimeout = 5 * time.Second
+
+// Server is a Hub served over a real websocket endpoint by an
+// httptest.Server, for tests exercising the full ServeWebsocket path.
+type Server struct {
+	// Hub is the Hub the Server registers connections with.
+	Hub *websocket.Hub
+	// HTTP is the underlying test server.
+	HTTP *httptest.Server
+	// URL is the websocket URL of the endpoint, such as ws://127.0.0.1:1234.
+	URL string
+
+	// registered tracks the addresses of the connections the Hub has
+	// registered.
+	registered *registrations
+}
+
+// NewServer starts a Server for hub, serving every connection with
+// websocket.ServeWebsocketWithOptions and options. NewServer runs hub, so
+// configure hub before calling it. Close the Server when the test is done.
+func NewServer(hub *websocket.Hub, options websocket.WebsocketOptions) *Server {
+	server := &Server{Hub: hub, registered: newRegistrations()}
+	hub.Observe(server.registered)
+	go hub.Run()
+	server.HTTP = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		websocket.ServeWebsocketWithOptions(hub, w, req, options)
+	}))
+	server.URL = "ws" + strings.TrimPrefix(server.HTTP.URL, "http")
+	return server
+}
+
+// Dial connects a new Conn to the Server, returning once the Hub has
+// registered it.
+func (s *Server) Dial() (*Conn, error) {
+	conn, _, err := gorilla.DefaultDialer.Dial(s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.registered.wait(conn.LocalAddr().String(), s.Hub.Done(), dialTimeout); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return newConn(conn), nil
+}
+
+// Close closes the Hub and the test server.
+func (s *Server) Close() {
+	s.Hub.Close()
+	s.HTTP.Close()
+}
+
+// registrations is a HubObserver tracking the remote addresses of the
+// registered clients that have one.
+type registrations struct {
+	websocket.BaseHubObserver
+
+	// mu guards all fields below.
+	mu sync.Mutex
+	// addrs are the remote addresses of the registered clients.
+	addrs map[string]bool
+	// changed is closed, and replaced, whenever a client is registered.
+	changed chan struct{}
+}
+
+func newRegistrations() *registrations {
+	return &registrations{addrs: make(map[string]bool), changed: make(chan struct{})}
+}
+
+// remoteAddr returns the remote address of client, if it has one.
+func remoteAddr(client websocket.Client) (string, bool) {
+	addresser, ok := client.(interface{ RemoteAddr() net.Addr })
+	if !ok {
+		return "", false
+	}
+	return addresser.RemoteAddr().String(), true
+}
+
+// Lossless implements websocket.LosslessObserver, since Dial waits for a
+// registration that must not be dropped.
+func (r *registrations) Lossless() bool {
+	return true
+}
+
+func (r *registrations) OnRegister(client websocket.Client, id string) {
+	addr, ok := remoteAddr(client)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addrs[addr] = true
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+func (r *registrations) OnUnregister(client websocket.Client, id string, reason websocket.UnregisterReason) {
+	addr, ok := remoteAddr(client)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.addrs, addr)
+}
+
+// wait waits up to timeout for a client with the remote address addr to
+// be registered.
+func (r *registrations) wait(addr string, hubDone <-chan struct{}, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		r.mu.Lock()
+		registered := r.addrs[addr]
+		changed := r.changed
+		r.mu.Unlock()
+		if registered {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-hubDone:
+			return websocket.ErrHubClosed
+		case <-timer.C:
+			return fmt.Errorf("waiting for the hub to register %s: %w", addr, ErrTimeout)
+		}
+	}
+}
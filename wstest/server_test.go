@@ -0,0 +1,188 @@
+package wstest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CooperCorona/websocket"
+	gorilla "github.com/gorilla/websocket"
+)
+
+// waitTimeout bounds every wait in these tests.
+const waitTimeout = 5 * time.Second
+
+// TestServerDial checks that Dial returns once the Hub has registered the
+// connection, and fails once the Hub has closed.
+func TestServerDial(t *testing.T) {
+	hub := websocket.NewHub()
+	server := NewServer(hub, websocket.WebsocketOptions{})
+	defer server.Close()
+
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	clients, err := hub.Clients()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 1 {
+		t.Fatalf("expected 1 client, got %d", len(clients))
+	}
+
+	hub.Close()
+	if _, err := server.Dial(); !errors.Is(err, websocket.ErrHubClosed) {
+		t.Errorf("expected ErrHubClosed, got %v", err)
+	}
+}
+
+func TestConnExpectEvent(t *testing.T) {
+	hub := websocket.NewHub()
+	server := NewServer(hub, websocket.WebsocketOptions{})
+	defer server.Close()
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	hub.BroadcastAll("first", []byte(`1`))
+	hub.BroadcastAll("second", []byte(`2`))
+	hub.BroadcastAll("first", []byte(`3`))
+
+	event, err := conn.ExpectEvent("second", waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(event.Data) != "2" {
+		t.Errorf("expected data 2, got %s", event.Data)
+	}
+	// The first "first" event was consumed by expecting "second".
+	event, err = conn.ExpectEvent("first", waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(event.Data) != "3" {
+		t.Errorf("expected data 3, got %s", event.Data)
+	}
+	if len(conn.Events()) != 3 {
+		t.Errorf("expected 3 events, got %d", len(conn.Events()))
+	}
+	if _, err := conn.ExpectEvent("missing", 10*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+}
+
+func TestConnSend(t *testing.T) {
+	hub := websocket.NewHub()
+	server := NewServer(hub, websocket.WebsocketOptions{})
+	defer server.Close()
+	client := NewClient()
+	hub.Register(client, websocket.ClientRegistrationOptions{})
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.Send("marshaled", map[string]string{"text": "cooper"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.SendEvent(websocket.Event{Name: "raw", Data: json.RawMessage(`[1,2]`)}); err != nil {
+		t.Fatal(err)
+	}
+	clientEvent, err := client.ExpectEvent("marshaled", waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(clientEvent.Event.Data) != `{"text":"cooper"}` {
+		t.Errorf("unexpected data %s", clientEvent.Event.Data)
+	}
+	clientEvent, err = client.ExpectEvent("raw", waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(clientEvent.Event.Data) != `[1,2]` {
+		t.Errorf("unexpected data %s", clientEvent.Event.Data)
+	}
+}
+
+// TestConnAcknowledges checks that a Conn acknowledges every delivery of
+// an event sent with an ID, but records retransmissions only once.
+func TestConnAcknowledges(t *testing.T) {
+	acks := make(chan websocket.Event, 2)
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := (&gorilla.Upgrader{}).Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		event := websocket.Event{Name: "message", Data: json.RawMessage(`1`), ID: "7"}
+		for i := 0; i < 2; i++ {
+			if conn.WriteJSON(event) != nil {
+				return
+			}
+			var ack websocket.Event
+			if conn.ReadJSON(&ack) != nil {
+				return
+			}
+			acks <- ack
+		}
+		conn.WriteJSON(websocket.Event{Name: "done"})
+		conn.ReadMessage()
+	}))
+	defer peer.Close()
+	dialed, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(peer.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := NewConn(dialed)
+	defer conn.Close()
+
+	if _, err := conn.ExpectEvent("done", waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		ack := <-acks
+		if ack.Name != websocket.AckEventName || string(ack.Data) != `"7"` {
+			t.Errorf("unexpected acknowledgement %s %s", ack.Name, ack.Data)
+		}
+	}
+	if events := conn.Events(); len(events) != 2 || events[0].Name != "message" {
+		t.Errorf("expected message to be recorded once, got %+v", events)
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	hub := websocket.NewHub()
+	server := NewServer(hub, websocket.WebsocketOptions{})
+	defer server.Close()
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closeErr := conn.CloseError(); closeErr != nil {
+		t.Errorf("expected no close error while open, got %v", closeErr)
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-conn.Closed():
+	case <-time.After(waitTimeout):
+		t.Fatal("connection did not close")
+	}
+	if closeErr := conn.CloseError(); closeErr == nil || closeErr.Code != gorilla.CloseNormalClosure {
+		t.Errorf("expected a normal closure, got %v", closeErr)
+	}
+	if _, err := conn.ExpectEvent("missing", waitTimeout); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}